@@ -1,6 +1,8 @@
 package fastxml
 
 import (
+	"fmt"
+	"strings"
 	"testing"
 )
 
@@ -90,6 +92,51 @@ func BenchmarkXMLReaderWithXPath(b *testing.B) {
 	}
 }
 
+func BenchmarkGetTokenEndIndexAttributes(b *testing.B) {
+	//start tag with many quoted attributes, scanning must stay linear
+	tag := []byte("<Tracking" + strings.Repeat(` event="start" offset="10%"`, 200) + ">")
+	for i := 0; i < b.N; i++ {
+		getTokenEndIndex(tag, 1, startXMLToken)
+	}
+}
+
+func BenchmarkXMLTokenizerSkipExcluded(b *testing.B) {
+	//rule excludes large Extensions subtree, LenientMode doesn't skip and tokenizes it for comparison
+	in := []byte(`<VAST><Ad><InLine><Impression>i</Impression><Extensions>` +
+		strings.Repeat(`<Extension type="x"><Data a="1" b="2">value</Data><!-- c --></Extension>`, 100) +
+		`</Extensions></InLine></Ad></VAST>`)
+	rule := GetXPath([][]string{{"VAST", "Ad", "InLine", "Impression"}})
+	for name, mode := range map[string]ParseMode{"skip": DefaultMode, "tokenize": LenientMode} {
+		tokenizer := NewXMLTokenizerWithOptions(ParseOptions{Mode: mode, MaxDepth: 64, MaxElements: 1000})
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				tokenizer.ParseWithXPath(in, rule, nil)
+			}
+		})
+	}
+}
+
+func BenchmarkXMLReaderEntities(b *testing.B) {
+	//many declared entities and references, entity lookups must not scan declarations
+	decls, refs := strings.Builder{}, strings.Builder{}
+	for i := 0; i < 2000; i++ {
+		fmt.Fprintf(&decls, `<!ENTITY e%d "v%d">`, i, i)
+		fmt.Fprintf(&refs, `&e%d;`, i)
+	}
+	in := []byte(`<!DOCTYPE VAST [` + decls.String() + `]><VAST>` + refs.String() + `</VAST>`)
+	reader := NewXMLReader()
+	for i := 0; i < b.N; i++ {
+		reader.Parse(in)
+		reader.Text(reader.SelectElement(nil, "VAST"))
+	}
+}
+
+func BenchmarkExtract(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		Extract([]byte(vastXMLString), "VAST", "Ad", "Wrapper", "Impression")
+	}
+}
+
 /*
 Running tool: /usr/local/src/go/bin/go test -benchmem -run=^$ -coverprofile=/var/folders/12/tnwntpbn5h3gjx_5gb30ngzm0000gn/T/vscode-go6QV7PF/go-code-cover -bench . vastevents/xmlparser
 
@@ -11,6 +11,8 @@ type compareXMLToken func(string, XMLToken) bool
 type treeNode struct {
 	data                   XMLToken
 	idx, first, last, next int
+	parent, prev           int  //set by xmlTree.insert, -1 for document node and first child
+	branch                 bool //has child elements, character data nodes are not counted
 }
 
 func (n treeNode) Data() XMLToken {
@@ -21,8 +23,9 @@ func (n treeNode) Index() int {
 	return n.idx
 }
 
+// IsLeaf reports whether node doesn't have child elements, it may still have text nodes
 func (n treeNode) IsLeaf() bool {
-	return n.first == -1
+	return !n.branch
 }
 
 type xmlTree struct {
@@ -36,12 +39,20 @@ NOTE: always re-fetch parent object everytime when inserting new object
 */
 func (t *xmlTree) insert(parent *treeNode, n treeNode) {
 	if len(t.nodes) == 0 {
-		t.nodes = append(t.nodes, treeNode{idx: 0, first: -1, last: -1, next: -1})
+		t.nodes = append(t.nodes, treeNode{idx: 0, first: -1, last: -1, next: -1, parent: -1, prev: -1})
 	}
 
 	n.idx = len(t.nodes)
+	n.parent = -1 //parent is not inserted yet, it links its children on insert
 	if parent == nil {
 		parent = &t.nodes[0]
+		n.parent = 0
+	}
+	n.prev = parent.last
+
+	//children are inserted before their parent
+	for i := n.first; i != -1; i = t.nodes[i].next {
+		t.nodes[i].parent = n.idx
 	}
 
 	if parent.first == -1 {
@@ -52,10 +63,30 @@ func (t *xmlTree) insert(parent *treeNode, n treeNode) {
 		t.nodes[parent.last].next = n.idx
 	}
 	parent.last = n.idx
+	if n.data.IsElement() {
+		parent.branch = true
+	}
 
 	t.nodes = append(t.nodes, n)
 }
 
+// getSibling returns nearest element sibling following node, or preceding it if prev is set
+func (t *xmlTree) getSibling(node *treeNode, prev bool) *treeNode {
+	for {
+		i := node.next
+		if prev {
+			i = node.prev
+		}
+		if i == -1 {
+			return nil
+		}
+		node = &t.nodes[i]
+		if node.data.IsElement() {
+			return node
+		}
+	}
+}
+
 func (t *xmlTree) reset() {
 	t.nodes = t.nodes[:0]
 }
@@ -107,11 +138,68 @@ func (t *xmlTree) getChilds(parent *treeNode) (result []*treeNode) {
 	}
 
 	for i := t.nodes[parentIndex].first; i != -1; i = t.nodes[i].next {
-		result = append(result, &t.nodes[i])
+		if t.nodes[i].data.IsElement() {
+			result = append(result, &t.nodes[i])
+		}
 	}
 	return
 }
 
+func (t *xmlTree) _getNodes(parent int, kind xmlTokenType, result *[]*treeNode) {
+	for i := t.nodes[parent].first; i != -1; i = t.nodes[i].next {
+		if t.nodes[i].data.kind == kind {
+			(*result) = append((*result), &t.nodes[i])
+		} else if t.nodes[i].data.IsElement() {
+			t._getNodes(i, kind, result)
+		}
+	}
+}
+
+// getNodes returns descendant nodes of given kind in document order
+func (t *xmlTree) getNodes(parent *treeNode, kind xmlTokenType) (result []*treeNode) {
+	parentIndex := 0
+	if parent != nil {
+		parentIndex = parent.idx
+	}
+	if parentIndex >= len(t.nodes) {
+		return nil
+	}
+	t._getNodes(parentIndex, kind, &result)
+	return
+}
+
+// _find appends element descendants of node at index matching f, returns true when first match is found and only first is needed
+func (t *xmlTree) _find(index, depth, maxDepth int, f func(*treeNode) bool, first bool, result *[]*treeNode) bool {
+	for i := t.nodes[index].first; i != -1; i = t.nodes[i].next {
+		if !t.nodes[i].data.IsElement() {
+			continue
+		}
+		if f(&t.nodes[i]) {
+			(*result) = append((*result), &t.nodes[i])
+			if first {
+				return true
+			}
+		}
+		if (maxDepth == 0 || depth < maxDepth) && t._find(i, depth+1, maxDepth, f, first, result) {
+			return true
+		}
+	}
+	return false
+}
+
+// find returns element descendants of parent matching f in document order, maxDepth 0 means unlimited depth
+func (t *xmlTree) find(parent *treeNode, maxDepth int, f func(*treeNode) bool, first bool) (result []*treeNode) {
+	parentIndex := 0
+	if parent != nil {
+		parentIndex = parent.idx
+	}
+	if parentIndex >= len(t.nodes) {
+		return nil
+	}
+	t._find(parentIndex, 1, maxDepth, f, first, &result)
+	return
+}
+
 func (t *xmlTree) _getPath(parent int, result *[]*treeNode, path ...string) {
 	for i := t.nodes[parent].first; i != -1; i = t.nodes[i].next {
 		if t.match != nil && t.match(path[0], t.nodes[i].data) {
@@ -167,11 +255,16 @@ func (t *xmlTree) getPathNode(parent *treeNode, path ...string) (result *treeNod
 
 func (t *xmlTree) iterate(f func(*treeNode)) {
 	for i := range t.nodes {
-		f(&t.nodes[i])
+		if t.nodes[i].data.IsElement() {
+			f(&t.nodes[i])
+		}
 	}
 }
 
 func (t *xmlTree) _traverse(index int, f func(*treeNode)) {
+	if !t.nodes[index].data.IsElement() {
+		return
+	}
 	f(&t.nodes[index])
 	for i := t.nodes[index].first; i != -1; i = t.nodes[i].next {
 		t._traverse(i, f)
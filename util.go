@@ -2,26 +2,16 @@ package fastxml
 
 import (
 	"bytes"
+	"fmt"
+	"unicode/utf8"
 )
 
 var (
-	whitespace    [256]bool //<space>, \r, \n, \t
-	alnum         [256]bool //a-z, A-Z, 0-9
-	alpha         [256]bool //a-z, A-Z
-	num           [256]bool //0-9
-	name          [256]bool //a-z, A-Z, 0-9, _, -
-	encodingChars = map[string]byte{
-		"amp;":  '&',
-		"apos;": '\'',
-		"lt;":   '<',
-		"gt;":   '>',
-		"quot;": '"',
-		"#39;":  '\'',
-		"#34;":  '"',
-		"#xA;":  '\n',
-		"#x9;":  '\t',
-		"#xD;":  '\r',
-	}
+	whitespace [256]bool //<space>, \r, \n, \t
+	alnum      [256]bool //a-z, A-Z, 0-9
+	alpha      [256]bool //a-z, A-Z
+	num        [256]bool //0-9
+	name       [256]bool //a-z, A-Z, 0-9, _, -, .
 )
 
 /*
@@ -59,6 +49,7 @@ func init() {
 	//name
 	name['_'] = true
 	name['-'] = true
+	name['.'] = true
 
 	//alnum
 	for ch := 'a'; ch <= 'z'; ch++ {
@@ -78,6 +69,72 @@ func init() {
 	}
 }
 
+// isNameStartRune reports whether r is NameStartChar of XML 1.0, excluding ':'
+func isNameStartRune(r rune) bool {
+	switch {
+	case r < utf8.RuneSelf:
+		return alpha[r] || r == '_'
+	case r >= 0xC0 && r <= 0xD6, r >= 0xD8 && r <= 0xF6, r >= 0xF8 && r <= 0x2FF,
+		r >= 0x370 && r <= 0x37D, r >= 0x37F && r <= 0x1FFF, r >= 0x200C && r <= 0x200D,
+		r >= 0x2070 && r <= 0x218F, r >= 0x2C00 && r <= 0x2FEF, r >= 0x3001 && r <= 0xD7FF,
+		r >= 0xF900 && r <= 0xFDCF, r >= 0xFDF0 && r <= 0xFFFD, r >= 0x10000 && r <= 0xEFFFF:
+		return true
+	}
+	return false
+}
+
+// isNameRune reports whether r is NameChar of XML 1.0, excluding ':'
+func isNameRune(r rune) bool {
+	switch {
+	case r < utf8.RuneSelf:
+		return name[r]
+	case r == 0xB7, r >= 0x300 && r <= 0x36F, r >= 0x203F && r <= 0x2040:
+		return true
+	}
+	return isNameStartRune(r)
+}
+
+// nameRuneLen returns length of multi-byte utf-8 name character at in[i], 0 if it is not a name character
+func nameRuneLen(in []byte, i int, start bool) int {
+	r, size := utf8.DecodeRune(in[i:])
+	if r == utf8.RuneError {
+		return 0
+	}
+	if (start && isNameStartRune(r)) || (!start && isNameRune(r)) {
+		return size
+	}
+	return 0
+}
+
+// scanName returns end index of name starting at in[i], i if there is no name
+func scanName(in []byte, i int) int {
+	if i >= len(in) || !isNameStart(in, i) {
+		return i
+	}
+	for i < len(in) {
+		if name[in[i]] || in[i] == ':' {
+			i++
+			continue
+		}
+		if in[i] >= utf8.RuneSelf {
+			if size := nameRuneLen(in, i, false); size > 0 {
+				i += size
+				continue
+			}
+		}
+		break
+	}
+	return i
+}
+
+// isNameStart reports whether name starts at in[i], ascii characters are checked using lookup table
+func isNameStart(in []byte, i int) bool {
+	if in[i] < utf8.RuneSelf {
+		return alpha[in[i]] || in[i] == '_'
+	}
+	return nameRuneLen(in, i, true) > 0
+}
+
 func _trimCDATA(in []byte, start, end int) (int, int, bool) {
 	//`#whitespaces#<![CDATA[ data ]]>#whitespaces#`
 	si, ei := _trim(in, start, end)
@@ -175,30 +232,176 @@ func escape[T []byte | string](w Writer, s T) {
 	}
 }
 
+// unescapeError reports invalid reference found at index of unescaped data
+type unescapeError struct {
+	index  int
+	reason string
+}
+
+func (e *unescapeError) Error() string {
+	return fmt.Sprintf("%s at index %d", e.reason, e.index)
+}
+
+// unescape decodes entities and character references, invalid references are written as it is
 func unescape(w Writer, s []byte) {
-	//TODO: use prefix tree for below if these functionality extends
+	u := unescaper{w: w}
+	_ = u.unescape(s, 0)
+}
+
+// unescapeStrict decodes entities and character references, returns error on invalid reference
+func unescapeStrict(w Writer, s []byte) error {
+	u := unescaper{w: w, strict: true}
+	return u.unescape(s, 0)
+}
+
+// unescaper decodes predefined entities, character references and entities declared in DOCTYPE
+type unescaper struct {
+	w        Writer
+	strict   bool
+	entities *entityTable
+	size     int //bytes expanded from declared entities
+}
 
+func (u *unescaper) unescape(s []byte, depth int) error {
+	w, strict := u.w, u.strict
 	for i := 0; i < len(s); i++ {
 		if s[i] != '&' {
 			w.WriteByte(s[i])
 			continue
 		}
 
-		// Check if the & is followed by a known entity
-		found := false
-		for key, val := range encodingChars {
-			if i+len(key) < len(s) && bytes.HasPrefix(s[i+1:], []byte(key)) {
-				w.WriteByte(val)
-				i += len(key)
-				found = true
-				break
+		// Check if the & is followed by character reference &#decimal; or &#xhex;
+		if i+1 < len(s) && s[i+1] == '#' {
+			ch, n, reason := parseCharRef(s[i:])
+			if n > 0 {
+				var b [utf8.UTFMax]byte
+				w.Write(b[:utf8.EncodeRune(b[:], ch)])
+				i += n - 1
+				continue
 			}
+			if strict {
+				return &unescapeError{index: i, reason: reason}
+			}
+			w.WriteByte(s[i])
+			continue
 		}
 
-		if !found {
+		// Check if the & is followed by a known entity
+		end := scanName(s, i+1)
+		if end == i+1 || end >= len(s) || s[end] != ';' {
+			if strict {
+				return &unescapeError{index: i, reason: "invalid entity reference"}
+			}
 			w.WriteByte(s[i])
+			continue
+		}
+
+		name := s[i+1 : end]
+		if ch, ok := predefinedEntity(name); ok {
+			w.WriteByte(ch)
+			i = end
+			continue
+		}
+
+		if ent := u.entities.lookup(name); ent != nil {
+			value := u.entities.in[ent.value.si:ent.value.ei]
+			var reason string
+			switch {
+			case ent.external:
+				reason = fmt.Sprintf("external entity &%s; is not supported", name)
+			case depth >= maxEntityDepth:
+				reason = fmt.Sprintf("entity &%s; exceeds expansion depth %d", name, maxEntityDepth)
+			case u.size+len(value) > maxEntityExpansion:
+				reason = fmt.Sprintf("entity &%s; exceeds expansion size %d", name, maxEntityExpansion)
+			default:
+				u.size += len(value)
+				if err := u.unescape(value, depth+1); err != nil {
+					//report error at the reference
+					return &unescapeError{index: i, reason: err.(*unescapeError).reason}
+				}
+				i = end
+				continue
+			}
+			if strict {
+				return &unescapeError{index: i, reason: reason}
+			}
+		} else if strict {
+			return &unescapeError{index: i, reason: fmt.Sprintf("undefined entity &%s;", name)}
+		}
+		w.WriteByte(s[i])
+	}
+	return nil
+}
+
+// predefinedEntity returns character of predefined xml entity
+func predefinedEntity(name []byte) (byte, bool) {
+	switch string(name) {
+	case "amp":
+		return '&', true
+	case "apos":
+		return '\'', true
+	case "lt":
+		return '<', true
+	case "gt":
+		return '>', true
+	case "quot":
+		return '"', true
+	}
+	return 0, false
+}
+
+// parseCharRef parses character reference at start of s, returns code point and reference length,
+// length is 0 along with reason if reference is invalid
+func parseCharRef(s []byte) (rune, int, string) {
+	//&#1114111; or &#x10FFFF;, search ';' only within the longest accepted reference
+	if n := len("&#x0010FFFF;") + 1; len(s) > n {
+		s = s[:n]
+	}
+	end := bytes.IndexByte(s, ';')
+	if end == -1 {
+		return 0, 0, "unterminated character reference"
+	}
+
+	digits, base := s[2:end], rune(10)
+	if len(digits) > 0 && digits[0] == 'x' {
+		digits, base = digits[1:], 16
+	}
+	if len(digits) == 0 {
+		return 0, 0, fmt.Sprintf("malformed character reference %s", s[:end+1])
+	}
+
+	var ch rune
+	for _, d := range digits {
+		var v rune
+		switch {
+		case d >= '0' && d <= '9':
+			v = rune(d - '0')
+		case base == 16 && d >= 'a' && d <= 'f':
+			v = rune(d-'a') + 10
+		case base == 16 && d >= 'A' && d <= 'F':
+			v = rune(d-'A') + 10
+		default:
+			return 0, 0, fmt.Sprintf("malformed character reference %s", s[:end+1])
+		}
+		ch = ch*base + v
+		if ch > utf8.MaxRune {
+			//stop before accumulator overflows
+			return 0, 0, fmt.Sprintf("invalid character reference %s", s[:end+1])
 		}
 	}
+
+	if !isXMLChar(ch) {
+		return 0, 0, fmt.Sprintf("invalid character reference %s", s[:end+1])
+	}
+	return ch, end + 1, ""
+}
+
+// isXMLChar reports whether r is Char of XML 1.0
+func isXMLChar(r rune) bool {
+	return r == 0x9 || r == 0xA || r == 0xD ||
+		(r >= 0x20 && r <= 0xD7FF) ||
+		(r >= 0xE000 && r <= 0xFFFD) ||
+		(r >= 0x10000 && r <= 0x10FFFF)
 }
 
 func unescapeBytes(s []byte) []byte {
@@ -17,6 +17,14 @@ func Test_unescape(t *testing.T) {
 		{name: `no_escape`, args: `abcdefg 01234 AABCDEF`, want: `abcdefg 01234 AABCDEF`},
 		{name: `all_escape`, args: `&lt;&quot;&apos;&amp;&apos;&quot;&gt;`, want: `<"'&'">`},
 		{name: `end_with_&`, args: `test&`, want: `test&`},
+		{name: `predefined_char_refs`, args: `&#39;&#34;&#xA;&#x9;&#xD;`, want: "'\"\n\t\r"},
+		{name: `decimal_char_ref`, args: `caf&#233; &#8217;s`, want: `café ’s`},
+		{name: `hex_char_ref`, args: `&#x20AC;10 &#X41; &#x1F600;`, want: `€10 &#X41; 😀`},
+		{name: `invalid_code_point`, args: `a&#0;b&#xD800;c&#x110000;`, want: `a&#0;b&#xD800;c&#x110000;`},
+		{name: `malformed_char_ref`, args: `&#; &#x; &#12a; &#65`, want: `&#; &#x; &#12a; &#65`},
+		{name: `overflow_char_ref`, args: `&#4294967361;&#x100000041;&#x10000003C;`, want: `&#4294967361;&#x100000041;&#x10000003C;`},
+		{name: `padded_char_ref`, args: `&#x0010FFFD;&#0000065;`, want: "\U0010FFFDA"},
+		{name: `semicolon_beyond_char_ref`, args: `&#65 some text;`, want: `&#65 some text;`},
 		// TODO: Add test cases.
 	}
 	for _, tt := range tests {
@@ -139,3 +147,35 @@ func TestSkipToken(t *testing.T) {
 		})
 	}
 }
+
+func Test_unescapeStrict(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    string
+		want    string
+		wantErr string
+	}{
+		{name: `valid`, args: `caf&#233; &amp; &#x20AC;`, want: `café & €`},
+		{name: `invalid_code_point`, args: `ab&#0;`, wantErr: `invalid character reference &#0; at index 2`},
+		{name: `surrogate`, args: `&#xD800;`, wantErr: `invalid character reference &#xD800; at index 0`},
+		{name: `out_of_range`, args: `&#xFFFFFFFF;`, wantErr: `invalid character reference &#xFFFFFFFF; at index 0`},
+		{name: `overflow_decimal`, args: `&#4294967361;`, wantErr: `invalid character reference &#4294967361; at index 0`},
+		{name: `overflow_hex`, args: `a&#x100000041;`, wantErr: `invalid character reference &#x100000041; at index 1`},
+		{name: `overflow_markup`, args: `&#x10000003C;`, wantErr: `invalid character reference &#x10000003C; at index 0`},
+		{name: `malformed`, args: `x &#12a;`, wantErr: `malformed character reference &#12a; at index 2`},
+		{name: `unterminated`, args: `&#65`, wantErr: `unterminated character reference at index 0`},
+		{name: `semicolon_beyond_char_ref`, args: `a&#65 some text;`, wantErr: `unterminated character reference at index 1`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := bytes.Buffer{}
+			err := unescapeStrict(&buf, []byte(tt.args))
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, buf.String())
+		})
+	}
+}
@@ -1,5 +1,9 @@
 package fastxml
 
+import (
+	"unicode/utf8"
+)
+
 type Attribute struct {
 	key, value xmlTagIndex
 }
@@ -12,36 +16,59 @@ func (a Attribute) Value(in []byte) []byte {
 	return in[a.value.si:a.value.ei]
 }
 
+// Prefix returns namespace prefix of attribute key, empty if not prefixed
+func (a Attribute) Prefix(in []byte) []byte {
+	if a.key.si == 0 || in[a.key.si-1] != ':' {
+		return nil
+	}
+	si := a.key.si - 1
+	for si > 0 && !whitespace[in[si-1]] {
+		si--
+	}
+	return in[si : a.key.si-1]
+}
+
+// QName returns qualified name of attribute, prefix:key if prefixed
+func (a Attribute) QName(in []byte) []byte {
+	if prefix := a.Prefix(in); len(prefix) != 0 {
+		return in[a.key.si-len(prefix)-1 : a.key.ei]
+	}
+	return a.Key(in)
+}
+
 // String function print key and value
 func (a Attribute) String(in []byte) string {
 	return string(in[a.key.si : a.value.ei+1])
 }
 
 func parseAttributes(in []byte, si, ei int) (attributes []Attribute) {
-	found := true
-	for found {
-		var attr Attribute
+	for {
+		attr, found := nextAttribute(in, si, ei)
+		if !found {
+			return
+		}
+		attributes = append(attributes, attr)
+		si = attr.value.ei + 1
+	}
+}
 
-		//parsing key
-		attr.key.si, attr.key.ei, found = _parseKey(in, si, ei)
-		if found {
-			//parsing = separator
-			i := attr.key.ei
-			for ; i < ei && whitespace[in[i]]; i = i + 1 {
-			}
-			if i > ei || in[i] != '=' {
-				//invalid
-				break
-			}
-			//parsing value
-			attr.value.si, attr.value.ei, found = _parseValue(in, i+1, ei)
+// nextAttribute parses first attribute found in in[si:ei]
+func nextAttribute(in []byte, si, ei int) (attr Attribute, found bool) {
+	//parsing key
+	attr.key.si, attr.key.ei, found = _parseKey(in, si, ei)
+	if found {
+		//parsing = separator
+		i := attr.key.ei
+		for ; i < ei && whitespace[in[i]]; i = i + 1 {
 		}
-		if found {
-			attributes = append(attributes, attr)
-			si = attr.value.ei + 1
+		if i > ei || in[i] != '=' {
+			//invalid
+			return attr, false
 		}
+		//parsing value
+		attr.value.si, attr.value.ei, found = _parseValue(in, i+1, ei)
 	}
-	return
+	return attr, found
 }
 
 func _parseKey(in []byte, si, ei int) (int, int, bool) {
@@ -57,11 +84,17 @@ func _parseKey(in []byte, si, ei int) (int, int, bool) {
 			si = ei + 1
 			continue
 		}
+		if in[ei] >= utf8.RuneSelf {
+			if size := nameRuneLen(in, ei, false); size > 0 {
+				ei += size - 1
+				continue
+			}
+		}
 		if !name[in[ei]] {
 			break
 		}
 	}
-	if ei < len && (alpha[in[si]] || in[si] == '_') {
+	if ei < len && isNameStart(in, si) {
 		return si, ei, true
 	}
 	return 0, 0, false
@@ -51,6 +51,16 @@ func Test_parseKey(t *testing.T) {
 			in:   `:key="value"  `,
 			want: want{value: ``, found: false},
 		},
+		{
+			name: "unicode_key",
+			in:   ` prénom="value"  `,
+			want: want{value: `prénom`, found: true},
+		},
+		{
+			name: "dotted_key",
+			in:   ` ad.id="value"  `,
+			want: want{value: `ad.id`, found: true},
+		},
 		{
 			name: "valid_with_whitespaces",
 			in:   ` key = "value"  `,
@@ -257,3 +267,13 @@ func Test_parseAttributes(t *testing.T) {
 		})
 	}
 }
+
+func TestAttributeQName(t *testing.T) {
+	in := []byte(` id="1" xlink:id="2"`)
+	attrs := parseAttributes(in, 0, len(in))
+	if assert.Len(t, attrs, 2) {
+		assert.Equal(t, "id", string(attrs[0].QName(in)))
+		assert.Equal(t, "id", string(attrs[1].Key(in)))
+		assert.Equal(t, "xlink:id", string(attrs[1].QName(in)))
+	}
+}
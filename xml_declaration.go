@@ -0,0 +1,70 @@
+package fastxml
+
+import (
+	"bytes"
+	"strings"
+)
+
+// DeclarationField is pseudo attribute of xml declaration, offsets are zero if field is not present
+type DeclarationField struct {
+	Value      string
+	Start, End int //offsets of value in document
+}
+
+// Declaration is xml declaration <?xml version="1.0" encoding="UTF-8" standalone="yes"?> of document
+type Declaration struct {
+	Start, End int //offsets of declaration in document
+	Version    DeclarationField
+	Encoding   DeclarationField
+	Standalone DeclarationField
+
+	OriginalEncoding string //encoding declared by document before it was transcoded to UTF-8, empty if not transcoded
+}
+
+// parseDeclaration returns xml declaration found at start of document, nil if not present
+func parseDeclaration(in []byte) *Declaration {
+	if !bytes.HasPrefix(in, xmlDecl) || len(in) == len(xmlDecl) || !whitespace[in[len(xmlDecl)]] {
+		return nil
+	}
+	end := bytes.Index(in, []byte("?>"))
+	if end == -1 {
+		return nil
+	}
+
+	decl := &Declaration{Start: 0, End: end + 2}
+	for _, attr := range parseAttributes(in, len(xmlDecl), end) {
+		field := DeclarationField{Value: string(attr.Value(in)), Start: attr.value.si, End: attr.value.ei}
+		switch string(attr.Key(in)) {
+		case "version":
+			decl.Version = field
+		case "encoding":
+			decl.Encoding = field
+		case "standalone":
+			decl.Standalone = field
+		}
+	}
+	return decl
+}
+
+// declarationXML returns xml declaration, empty encoding and standalone fields are omitted
+func declarationXML(version, encoding, standalone string) string {
+	if version == "" {
+		version = "1.0"
+	}
+	var sb strings.Builder
+	sb.WriteString(`<?xml version="`)
+	sb.WriteString(version)
+	sb.WriteByte('"')
+	if encoding != "" {
+		sb.WriteString(` encoding="`)
+		sb.WriteString(encoding)
+		sb.WriteByte('"')
+	}
+	if standalone != "" {
+		sb.WriteString(` standalone="`)
+		sb.WriteString(standalone)
+		sb.WriteByte('"')
+	}
+	sb.WriteString(`?>`)
+	return sb.String()
+}
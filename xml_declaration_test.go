@@ -0,0 +1,98 @@
+package fastxml
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestXMLReaderDeclaration(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want *Declaration
+	}{
+		{
+			name: "all_fields",
+			in:   `<?xml version="1.0" encoding='UTF-8' standalone="yes"?><a/>`,
+			want: &Declaration{
+				Start: 0, End: 55,
+				Version:    DeclarationField{Value: "1.0", Start: 15, End: 18},
+				Encoding:   DeclarationField{Value: "UTF-8", Start: 30, End: 35},
+				Standalone: DeclarationField{Value: "yes", Start: 49, End: 52},
+			},
+		},
+		{
+			name: "version_only",
+			in:   "<?xml version=\"1.1\"?>\n<a/>",
+			want: &Declaration{End: 21, Version: DeclarationField{Value: "1.1", Start: 15, End: 18}},
+		},
+		{
+			name: "transcoded",
+			in:   "<?xml version=\"1.0\" encoding=\"windows-1252\"?><a>\x80</a>",
+			want: &Declaration{
+				End:      38,
+				Version:  DeclarationField{Value: "1.0", Start: 15, End: 18},
+				Encoding: DeclarationField{Value: "UTF-8", Start: 30, End: 35}, //value at its offsets in RawXML
+
+				OriginalEncoding: "windows-1252",
+			},
+		},
+		{name: "missing", in: `<a/>`},
+		{name: "other_processing_instruction", in: `<?xml-stylesheet href="a.xsl"?><a/>`},
+		{name: "not_at_start", in: ` <?xml version="1.0"?><a/>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := NewXMLReader()
+			if assert.NoError(t, reader.Parse([]byte(tt.in))) {
+				assert.Equal(t, tt.want, reader.Declaration())
+			}
+		})
+	}
+}
+
+func TestXMLUpdaterDeclaration(t *testing.T) {
+	tests := []struct {
+		name   string
+		in     string
+		update func(*XMLUpdater)
+		want   string
+	}{
+		{
+			name:   "set_missing",
+			in:     `<a>1</a>`,
+			update: func(xu *XMLUpdater) { xu.SetDeclaration("", "UTF-8", "") },
+			want:   `<?xml version="1.0" encoding="UTF-8"?><a>1</a>`,
+		},
+		{
+			name:   "replace_existing",
+			in:     "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?>\n<a>1</a>",
+			update: func(xu *XMLUpdater) { xu.SetDeclaration("1.0", "UTF-8", "yes") },
+			want:   "<?xml version=\"1.0\" encoding=\"UTF-8\" standalone=\"yes\"?>\n<a>1</a>",
+		},
+		{
+			name:   "remove",
+			in:     "<?xml version=\"1.0\"?>\n<a>1</a>",
+			update: func(xu *XMLUpdater) { xu.RemoveDeclaration() },
+			want:   `<a>1</a>`,
+		},
+		{
+			name:   "remove_missing",
+			in:     `<a>1</a>`,
+			update: func(xu *XMLUpdater) { xu.RemoveDeclaration() },
+			want:   `<a>1</a>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := NewXMLReader()
+			if !assert.NoError(t, reader.Parse([]byte(tt.in))) {
+				return
+			}
+			updater := NewXMLUpdater(reader, WriteSettings{})
+			tt.update(updater)
+			assert.Equal(t, tt.want, updater.String())
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package fastxml
+
+import (
+	"bytes"
+)
+
+// exported token kinds returned by Token.Kind
+const (
+	UnknownTokenType   TokenType = unknownXMLToken
+	StartXMLToken      TokenType = startXMLToken
+	InlineXMLToken     TokenType = inlineXMLToken
+	EndXMLToken        TokenType = endXMLToken
+	ProcessingXMLToken TokenType = processingXMLToken
+	CommentsXMLToken   TokenType = commentsXMLToken
+	CDATAToken         TokenType = cdataXMLToken
+	DOCTYPEToken       TokenType = doctypeXMLToken
+	TextToken          TokenType = textToken
+)
+
+// Token is a single lexical token of xml document returned by Decoder
+type Token struct {
+	Kind       TokenType
+	Name       []byte //element name without namespace, processing instruction target
+	Raw        []byte //token bytes as present in input
+	Start, End int    //offsets of Raw in input
+	Depth      int    //number of elements enclosing the token
+
+	in []byte
+}
+
+// Attributes returns attributes of start or inline element token, offsets are relative to input
+func (t Token) Attributes() []Attribute {
+	if t.Kind != StartXMLToken && t.Kind != InlineXMLToken {
+		return nil
+	}
+	offset := 1
+	if t.Kind == InlineXMLToken {
+		offset = 2
+	}
+	_, nei := getTokenNameIndex(t.in, t.Start+1)
+	return parseAttributes(t.in, nei, t.End-offset)
+}
+
+// Attr returns value of attribute key of start or inline element token
+func (t Token) Attr(key string) ([]byte, bool) {
+	for _, attr := range t.Attributes() {
+		if bytes.Equal(attr.Key(t.in), []byte(key)) {
+			return attr.Value(t.in), true
+		}
+	}
+	return nil, false
+}
+
+/*
+Decoder is a pull parser returning every token of the document in source order,
+
+	dec := NewDecoder(in)
+	for dec.Next() {
+		tok := dec.Token()
+	}
+	if err := dec.Err(); err != nil {}
+*/
+type Decoder struct {
+	in    []byte
+	index int
+	tok   Token
+	err   error
+	names stack[xmlTagIndex] //names of open elements
+}
+
+func NewDecoder(in []byte) *Decoder {
+	d := &Decoder{}
+	d.Reset(in)
+	return d
+}
+
+// Reset reuses decoder for new input
+func (d *Decoder) Reset(in []byte) {
+	d.in = in
+	d.index = 0
+	d.tok = Token{}
+	d.err = nil
+	d.names.data = d.names.data[:0]
+}
+
+// Token returns current token, valid after Next returns true
+func (d *Decoder) Token() Token {
+	return d.tok
+}
+
+// Err returns parsing error which stopped Next
+func (d *Decoder) Err() error {
+	return d.err
+}
+
+// Next advances decoder to next token, returns false at end of input or on error
+func (d *Decoder) Next() bool {
+	if d.err != nil {
+		return false
+	}
+
+	in, i := d.in, d.index
+	if i >= len(in) {
+		if d.names.len() != 0 {
+			//report at start tag, it begins with < before name and prefix
+			name := d.names.peek()
+			d.err = newSyntaxError(in, bytes.LastIndexByte(in[:name.si], '<'), startXMLToken, "unclosed element <%s> at EOF", in[name.si:name.ei])
+		}
+		return false
+	}
+
+	d.tok = Token{Start: i, Depth: d.names.len(), in: in}
+
+	if in[i] != '<' {
+		//text till next token
+		end := bytes.IndexByte(in[i:], '<')
+		if end == -1 {
+			end = len(in)
+		} else {
+			end += i
+		}
+		d.tok.Kind = TextToken
+		return d.setToken(end)
+	}
+
+	ttype := getTokenType(in, i+1)
+	endIndex, inlineToken := getTokenEndIndex(in, i+1, ttype)
+	if endIndex == -1 {
+		d.err = newSyntaxError(in, i, ttype, unterminatedReason(ttype))
+		return false
+	}
+	d.tok.Kind = ttype
+
+	switch ttype {
+	case startXMLToken:
+		nsi, nei := getTokenNameIndex(in, i+1)
+		d.tok.Name = in[nsi:nei]
+		if inlineToken {
+			d.tok.Kind = inlineXMLToken
+		} else {
+			d.names.push(xmlTagIndex{si: nsi, ei: nei})
+		}
+	case endXMLToken:
+		nsi, nei := getTokenNameIndex(in, i+2)
+		d.tok.Name = in[nsi:nei]
+		name := d.names.pop()
+		if name == nil {
+			d.err = newSyntaxError(in, i, endXMLToken, "unexpected end tag </%s>", d.tok.Name)
+			return false
+		}
+		if !bytes.Equal(in[name.si:name.ei], d.tok.Name) {
+			d.err = newSyntaxError(in, i, endXMLToken, "mismatched end tag </%s> for <%s>", d.tok.Name, in[name.si:name.ei])
+			return false
+		}
+		d.tok.Depth = d.names.len()
+	case processingXMLToken:
+		d.tok.Name = in[i+2 : piTargetEnd(in, i+2, endIndex-2)]
+	}
+	return d.setToken(endIndex)
+}
+
+func (d *Decoder) setToken(end int) bool {
+	d.tok.End = end
+	d.tok.Raw = d.in[d.tok.Start:end]
+	d.index = end
+	return true
+}
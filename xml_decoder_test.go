@@ -0,0 +1,119 @@
+package fastxml
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecoder(t *testing.T) {
+	in := []byte(`<?xml version="1.0"?><!DOCTYPE ad><!-- c --><Ad id="1"><Impression><![CDATA[http://i.com]]></Impression><Tracking event="start"/>text &amp; more</Ad>`)
+
+	var actual []string
+	dec := NewDecoder(in)
+	for dec.Next() {
+		tok := dec.Token()
+		assert.Equal(t, string(in[tok.Start:tok.End]), string(tok.Raw))
+		actual = append(actual, fmt.Sprintf("%s:%d:%s:%s", tok.Kind, tok.Depth, tok.Name, tok.Raw))
+	}
+	assert.NoError(t, dec.Err())
+	assert.Equal(t, []string{
+		`ProcessingXMLToken:0:xml:<?xml version="1.0"?>`,
+		`DOCTYPEToken:0::<!DOCTYPE ad>`,
+		`CommentsXMLToken:0::<!-- c -->`,
+		`StartXMLToken:0:Ad:<Ad id="1">`,
+		`StartXMLToken:1:Impression:<Impression>`,
+		`CDATAToken:2::<![CDATA[http://i.com]]>`,
+		`EndXMLToken:1:Impression:</Impression>`,
+		`InlineXMLToken:1:Tracking:<Tracking event="start"/>`,
+		`TextToken:1::text &amp; more`,
+		`EndXMLToken:0:Ad:</Ad>`,
+	}, actual)
+}
+
+func TestDecoderRawRoundTrip(t *testing.T) {
+	in := []byte(xml)
+	buf := bytes.Buffer{}
+	dec := NewDecoder(in)
+	for dec.Next() {
+		buf.Write(dec.Token().Raw)
+	}
+	assert.NoError(t, dec.Err())
+	assert.Equal(t, xml, buf.String())
+}
+
+func TestDecoderAttributes(t *testing.T) {
+	in := []byte(`<Tracking event="start" offset='>'/><Ad>`)
+	dec := NewDecoder(in)
+	if !assert.True(t, dec.Next()) {
+		return
+	}
+	tok := dec.Token()
+	assert.Len(t, tok.Attributes(), 2)
+
+	value, ok := tok.Attr("offset")
+	assert.True(t, ok)
+	assert.Equal(t, ">", string(value))
+
+	_, ok = tok.Attr("missing")
+	assert.False(t, ok)
+
+	assert.True(t, dec.Next())
+	assert.False(t, dec.Next())
+	assert.ErrorIs(t, dec.Err(), ErrInvalidXML)
+}
+
+func TestDecoderErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "mismatched", input: `<a><b></a>`, want: "mismatched end tag </a> for <b>"},
+		{name: "unexpected", input: `<a></a></b>`, want: "unexpected end tag </b>"},
+		{name: "unterminated", input: `<a><![CDATA[x</a>`, want: "unterminated CDATA section"},
+		{name: "unclosed", input: `<a>text`, want: "unclosed element <a> at EOF"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dec := NewDecoder([]byte(tt.input))
+			for dec.Next() {
+			}
+			err, ok := dec.Err().(*SyntaxError)
+			if assert.True(t, ok) {
+				assert.Equal(t, tt.want, err.Reason)
+			}
+		})
+	}
+}
+
+func TestDecoderErrorOffsets(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  SyntaxError
+	}{
+		{
+			name:  "unclosed",
+			input: "<a>\n  <x:b>text",
+			want:  SyntaxError{Offset: 6, Line: 2, Column: 3, Token: startXMLToken, Reason: "unclosed element <b> at EOF"},
+		},
+		{
+			name:  "mismatched",
+			input: "<a>\n<b></a>",
+			want:  SyntaxError{Offset: 7, Line: 2, Column: 4, Token: endXMLToken, Reason: "mismatched end tag </a> for <b>"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dec := NewDecoder([]byte(tt.input))
+			for dec.Next() {
+			}
+			assert.Equal(t, &tt.want, dec.Err())
+			//XMLTokenizer reports the same position
+			assert.Equal(t, &tt.want, NewXMLTokenizer().Parse([]byte(tt.input), nil))
+		})
+	}
+}
@@ -0,0 +1,275 @@
+package fastxml
+
+import (
+	"bytes"
+)
+
+const (
+	maxEntityDepth     = 8       //max nesting of entity references while expanding entity
+	maxEntityExpansion = 1 << 20 //max bytes expanded from declared entities
+)
+
+var (
+	doctypeStart = []byte("<!DOCTYPE")
+	entityStart  = []byte("<!ENTITY")
+)
+
+// entity is internal or external general entity declared in DOCTYPE internal subset
+type entity struct {
+	decl        int //offset of <!ENTITY declaration
+	name, value xmlTagIndex
+	external    bool //external entities are never fetched
+}
+
+type entityTable struct {
+	in       []byte
+	entities []entity
+	index    map[string]int //entity name to index of its first declaration
+}
+
+func (et *entityTable) reset(in []byte) {
+	et.in = in
+	et.entities = et.entities[:0]
+	for name := range et.index {
+		delete(et.index, name)
+	}
+}
+
+// add appends declared entity, redeclaration of existing name is ignored for lookups
+func (et *entityTable) add(ent entity) {
+	name := string(et.in[ent.name.si:ent.name.ei])
+	if et.index == nil {
+		et.index = map[string]int{}
+	}
+	if _, ok := et.index[name]; !ok {
+		et.index[name] = len(et.entities)
+	}
+	et.entities = append(et.entities, ent)
+}
+
+// lookup returns entity by name, first declaration is binding one
+func (et *entityTable) lookup(name []byte) *entity {
+	if i := et.indexOf(name); i != -1 {
+		return &et.entities[i]
+	}
+	return nil
+}
+
+func (et *entityTable) indexOf(name []byte) int {
+	if et == nil {
+		return -1
+	}
+	if i, ok := et.index[string(name)]; ok {
+		return i
+	}
+	return -1
+}
+
+// skipMarkupDecl returns index after markup declaration, comment or processing instruction starting at in[i], -1 if not terminated
+func skipMarkupDecl(in []byte, i int) int {
+	if bytes.HasPrefix(in[i:], []byte("<!--")) {
+		if end := bytes.Index(in[i+4:], []byte("-->")); end != -1 {
+			return i + 4 + end + 3
+		}
+		return -1
+	}
+	if bytes.HasPrefix(in[i:], []byte("<?")) {
+		if end := bytes.Index(in[i+2:], []byte("?>")); end != -1 {
+			return i + 2 + end + 2
+		}
+		return -1
+	}
+
+	var quote byte
+	for i++; i < len(in); i++ {
+		if quote != 0 {
+			if in[i] == quote {
+				quote = 0
+			}
+		} else if in[i] == '"' || in[i] == '\'' {
+			quote = in[i]
+		} else if in[i] == '>' {
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// doctypeEndIndex returns index after DOCTYPE token, -1 if not terminated
+func doctypeEndIndex(in []byte, startIndex int) int {
+	var quote byte
+	for i := startIndex; i < len(in); i++ {
+		if quote != 0 {
+			if in[i] == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch in[i] {
+		case '"', '\'':
+			quote = in[i]
+		case '>':
+			return i + 1
+		case '[':
+			//internal subset, contains markup declarations, parameter entity references and whitespaces
+			for i++; i < len(in) && in[i] != ']'; {
+				if in[i] != '<' {
+					i++
+					continue
+				}
+				if i = skipMarkupDecl(in, i); i == -1 {
+					return -1
+				}
+			}
+		}
+	}
+	return -1
+}
+
+// parseDoctype collects general entities declared in internal subset of DOCTYPE token in[si:ei]
+func (sp *XMLTokenizer) parseDoctype(in []byte, si, ei int) error {
+	sp.entities.reset(in)
+
+	var quote byte
+	i := si + len(doctypeStart)
+	for ; i < ei; i++ {
+		if quote != 0 {
+			if in[i] == quote {
+				quote = 0
+			}
+		} else if in[i] == '"' || in[i] == '\'' {
+			quote = in[i]
+		} else if in[i] == '[' {
+			break
+		}
+	}
+
+	for i++; i < ei && in[i] != ']'; {
+		if in[i] != '<' {
+			i++
+			continue
+		}
+		end := skipMarkupDecl(in, i)
+		if end == -1 {
+			break
+		}
+		if bytes.HasPrefix(in[i:end], entityStart) {
+			if ent, ok := parseEntityDecl(in, i, end); ok {
+				sp.entities.add(ent)
+			}
+		}
+		i = end
+	}
+	return sp.checkEntities(in)
+}
+
+// parseEntityDecl parses general entity declaration in[si:ei], parameter entities are ignored
+func parseEntityDecl(in []byte, si, ei int) (entity, bool) {
+	ent := entity{decl: si}
+
+	i := si + len(entityStart)
+	for ; i < ei && whitespace[in[i]]; i++ {
+	}
+	if i >= ei || in[i] == '%' {
+		return ent, false
+	}
+
+	end := scanName(in, i)
+	if end == i {
+		return ent, false
+	}
+	ent.name = xmlTagIndex{si: i, ei: end}
+
+	for i = end; i < ei && whitespace[in[i]]; i++ {
+	}
+	if i >= ei {
+		return ent, false
+	}
+
+	if in[i] == '"' || in[i] == '\'' {
+		end := bytes.IndexByte(in[i+1:ei], in[i])
+		if end == -1 {
+			return ent, false
+		}
+		ent.value = xmlTagIndex{si: i + 1, ei: i + 1 + end}
+		return ent, true
+	}
+
+	if bytes.HasPrefix(in[i:ei], []byte("SYSTEM")) || bytes.HasPrefix(in[i:ei], []byte("PUBLIC")) {
+		ent.external = true
+		return ent, true
+	}
+	return ent, false
+}
+
+// checkEntities rejects recursive entities and entities exceeding expansion limits
+func (sp *XMLTokenizer) checkEntities(in []byte) error {
+	if len(sp.entities.entities) == 0 {
+		return nil
+	}
+	sizes := make([]int, 2*len(sp.entities.entities))
+	for i := range sizes {
+		sizes[i] = -1
+	}
+	for i := range sp.entities.entities {
+		if _, _, err := sp.entitySize(in, i, sizes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+entitySize returns expanded size and nesting depth of entity, sizes holds size followed by depth of each entity,
+size is -1 for unknown and -2 for entity being expanded
+*/
+func (sp *XMLTokenizer) entitySize(in []byte, index int, sizes []int) (int, int, error) {
+	ent := &sp.entities.entities[index]
+	name := in[ent.name.si:ent.name.ei]
+
+	switch sizes[2*index] {
+	case -1:
+	case -2:
+		return 0, 0, newSyntaxError(in, ent.decl, doctypeXMLToken, "recursive entity &%s;", name)
+	default:
+		return sizes[2*index], sizes[2*index+1], nil
+	}
+
+	sizes[2*index] = -2
+	size, depth := 0, 1
+	value := in[ent.value.si:ent.value.ei]
+	for i := 0; i < len(value); i++ {
+		if value[i] != '&' {
+			size++
+			continue
+		}
+		end := scanName(value, i+1)
+		if end == i+1 || end >= len(value) || value[end] != ';' {
+			size++
+			continue
+		}
+		ref := sp.entities.indexOf(value[i+1 : end])
+		if ref == -1 || sp.entities.entities[ref].external {
+			size++
+			continue
+		}
+		n, d, err := sp.entitySize(in, ref, sizes)
+		if err != nil {
+			return 0, 0, err
+		}
+		size += n
+		if d+1 > depth {
+			depth = d + 1
+		}
+		i = end
+	}
+
+	if depth > maxEntityDepth {
+		return 0, 0, newSyntaxError(in, ent.decl, doctypeXMLToken, "entity &%s; exceeds expansion depth %d", name, maxEntityDepth)
+	}
+	if size > maxEntityExpansion {
+		return 0, 0, newSyntaxError(in, ent.decl, doctypeXMLToken, "entity &%s; exceeds expansion size %d", name, maxEntityExpansion)
+	}
+	sizes[2*index], sizes[2*index+1] = size, depth
+	return size, depth, nil
+}
@@ -0,0 +1,115 @@
+package fastxml
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDoctypeEntities(t *testing.T) {
+	in := []byte(`<?xml version="1.0"?>
+<!DOCTYPE VAST [
+	<!-- entities used by creatives ]> -->
+	<!ENTITY company "Acme &amp; Co">
+	<!ENTITY brand "&company; &#x2122;">
+	<!ENTITY company "ignored redeclaration">
+	<!ENTITY % param "ignored">
+	<!ENTITY logo SYSTEM "http://example.com/logo.xml">
+	<!ELEMENT VAST (Ad)*>
+	<!ATTLIST Ad id CDATA #REQUIRED note CDATA "a>b">
+	<?pi data?>
+]>
+<VAST><Ad id="&brand;"><AdTitle>&brand; by &company;</AdTitle><Logo>&logo;</Logo><Unknown>&missing;</Unknown></Ad></VAST>`)
+
+	reader := NewXMLReader()
+	if !assert.NoError(t, reader.Parse(in)) {
+		return
+	}
+
+	ad := reader.SelectElement(nil, "VAST", "Ad")
+	assert.Equal(t, "Acme & Co ™", reader.AttrValue(reader.SelectAttr(ad, "id")))
+	assert.Equal(t, "Acme & Co ™ by Acme & Co", reader.Text(reader.SelectElement(ad, "AdTitle")))
+
+	//external entities are never fetched
+	logo := reader.SelectElement(ad, "Logo")
+	assert.Equal(t, "&logo;", reader.Text(logo))
+	_, err := reader.TextStrict(logo)
+	assert.EqualError(t, err, "invalid xml: external entity &logo; is not supported at line 13, column 69 (offset 435)")
+
+	unknown := reader.SelectElement(ad, "Unknown")
+	assert.Equal(t, "&missing;", reader.Text(unknown))
+	_, err = reader.TextStrict(unknown)
+	assert.ErrorIs(t, err, ErrInvalidXML)
+}
+
+func TestDoctypeEntityLimits(t *testing.T) {
+	laughs := strings.Builder{}
+	laughs.WriteString(`<!DOCTYPE lolz [<!ENTITY lol0 "lollollollollollollollollollol">`)
+	for i := 1; i <= 6; i++ {
+		laughs.WriteString(`<!ENTITY lol` + string(rune('0'+i)) + ` "`)
+		for j := 0; j < 10; j++ {
+			laughs.WriteString(`&lol` + string(rune('0'+i-1)) + `;`)
+		}
+		laughs.WriteString(`">`)
+	}
+	laughs.WriteString(`]><lolz>&lol6;</lolz>`)
+
+	deep := strings.Builder{}
+	deep.WriteString(`<!DOCTYPE a [<!ENTITY e0 "x">`)
+	for i := 1; i <= 9; i++ {
+		deep.WriteString(`<!ENTITY e` + string(rune('0'+i)) + ` "&e` + string(rune('0'+i-1)) + `;">`)
+	}
+	deep.WriteString(`]><a>&e9;</a>`)
+
+	tests := []struct {
+		name   string
+		input  string
+		reason string
+	}{
+		{
+			name:   "billion_laughs",
+			input:  laughs.String(),
+			reason: "entity &lol5; exceeds expansion size 1048576",
+		},
+		{
+			name:   "expansion_depth",
+			input:  deep.String(),
+			reason: "entity &e8; exceeds expansion depth 8",
+		},
+		{
+			name:   "recursive_entity",
+			input:  `<!DOCTYPE a [<!ENTITY a "&b;"><!ENTITY b "x&a;">]><a>&a;</a>`,
+			reason: "recursive entity &a;",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewXMLReader().Parse([]byte(tt.input))
+			assert.ErrorIs(t, err, ErrInvalidXML)
+			if syntaxErr, ok := err.(*SyntaxError); assert.True(t, ok) {
+				assert.Equal(t, tt.reason, syntaxErr.Reason)
+				assert.Equal(t, DOCTYPEToken, syntaxErr.Token)
+			}
+		})
+	}
+}
+
+func Test_doctypeEndIndex(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want int
+	}{
+		{name: "no_subset", in: `<!DOCTYPE a SYSTEM "a>b.dtd"><a/>`, want: 29},
+		{name: "subset", in: `<!DOCTYPE a [<!ENTITY e "]>">]><a/>`, want: 31},
+		{name: "subset_comment", in: `<!DOCTYPE a [<!-- ]> -->]><a/>`, want: 26},
+		{name: "unterminated_subset", in: `<!DOCTYPE a [<!ENTITY e "x">`, want: -1},
+		{name: "unterminated_literal", in: `<!DOCTYPE a SYSTEM "a.dtd>`, want: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, doctypeEndIndex([]byte(tt.in), 1))
+		})
+	}
+}
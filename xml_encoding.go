@@ -0,0 +1,127 @@
+package fastxml
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// ErrUnsupportedEncoding is returned when document is not in UTF-8 and can't be transcoded
+var ErrUnsupportedEncoding = errors.New("unsupported xml encoding")
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+	xmlDecl    = []byte("<?xml")
+	encodingAt = []byte("encoding")
+)
+
+// windows1252 maps bytes 0x80-0x9F which differ from ISO-8859-1, zero for undefined bytes
+var windows1252 = [32]rune{
+	0x20AC, 0, 0x201A, 0x0192, 0x201E, 0x2026, 0x2020, 0x2021, 0x02C6, 0x2030, 0x0160, 0x2039, 0x0152, 0, 0x017D, 0,
+	0, 0x2018, 0x2019, 0x201C, 0x201D, 0x2022, 0x2013, 0x2014, 0x02DC, 0x2122, 0x0161, 0x203A, 0x0153, 0, 0x017E, 0x0178,
+}
+
+/*
+toUTF8 detects encoding of document from byte order mark and xml declaration and
+returns document transcoded to UTF-8 without BOM, UTF-8 input is returned as is.
+declared is encoding value of xml declaration rewritten to UTF-8 by transcoding, empty if not rewritten
+*/
+func toUTF8(in []byte) (out []byte, declared string, err error) {
+	switch {
+	case bytes.HasPrefix(in, bomUTF8):
+		return in[len(bomUTF8):], "", nil
+	case bytes.HasPrefix(in, bomUTF16LE):
+		return fromUTF16(in[len(bomUTF16LE):], false)
+	case bytes.HasPrefix(in, bomUTF16BE):
+		return fromUTF16(in[len(bomUTF16BE):], true)
+	case bytes.HasPrefix(in, []byte{'<', 0, '?', 0}):
+		return fromUTF16(in, false)
+	case bytes.HasPrefix(in, []byte{0, '<', 0, '?'}):
+		return fromUTF16(in, true)
+	}
+
+	si, ei := declaredEncoding(in)
+	if si == ei {
+		return in, "", nil
+	}
+	declared = string(in[si:ei])
+	switch strings.ToLower(declared) {
+	case "utf-8", "utf8", "us-ascii", "ascii":
+		return in, "", nil
+	case "iso-8859-1", "iso8859-1", "iso_8859-1", "latin1", "l1":
+		return fromSingleByte(in, si, ei, nil), declared, nil
+	case "windows-1252", "cp1252":
+		return fromSingleByte(in, si, ei, &windows1252), declared, nil
+	}
+	return nil, "", fmt.Errorf("%w: %s", ErrUnsupportedEncoding, declared)
+}
+
+// declaredEncoding returns offsets of encoding value in xml declaration at start of document
+func declaredEncoding(in []byte) (si, ei int) {
+	if !bytes.HasPrefix(in, xmlDecl) {
+		return 0, 0
+	}
+	end := bytes.Index(in, []byte("?>"))
+	if end == -1 {
+		return 0, 0
+	}
+	i := bytes.Index(in[:end], encodingAt)
+	if i == -1 {
+		return 0, 0
+	}
+	for i += len(encodingAt); i < end && (whitespace[in[i]] || in[i] == '='); i++ {
+	}
+	if i >= end || (in[i] != '"' && in[i] != '\'') {
+		return 0, 0
+	}
+	value := bytes.IndexByte(in[i+1:end], in[i])
+	if value == -1 {
+		return 0, 0
+	}
+	return i + 1, i + 1 + value
+}
+
+// fromSingleByte transcodes ISO-8859-1 or windows-1252 document, encoding value in[si:ei] is replaced with UTF-8
+func fromSingleByte(in []byte, si, ei int, table *[32]rune) []byte {
+	out := make([]byte, 0, len(in)+len(in)/8)
+	out = append(out, in[:si]...)
+	out = append(out, "UTF-8"...)
+	for _, ch := range in[ei:] {
+		r := rune(ch)
+		if table != nil && ch >= 0x80 && ch <= 0x9F && table[ch-0x80] != 0 {
+			r = table[ch-0x80]
+		}
+		out = utf8.AppendRune(out, r)
+	}
+	return out
+}
+
+// fromUTF16 transcodes UTF-16 document without BOM, encoding in xml declaration is replaced with UTF-8 and returned as declared
+func fromUTF16(in []byte, bigEndian bool) (out []byte, declared string, err error) {
+	if len(in)%2 != 0 {
+		return nil, "", fmt.Errorf("%w: odd length of UTF-16 document", ErrUnsupportedEncoding)
+	}
+	units := make([]uint16, len(in)/2)
+	for i := range units {
+		if bigEndian {
+			units[i] = uint16(in[2*i])<<8 | uint16(in[2*i+1])
+		} else {
+			units[i] = uint16(in[2*i+1])<<8 | uint16(in[2*i])
+		}
+	}
+	out = make([]byte, 0, len(units))
+	for _, r := range utf16.Decode(units) {
+		out = utf8.AppendRune(out, r)
+	}
+
+	if si, ei := declaredEncoding(out); si != ei {
+		declared = string(out[si:ei])
+		out = append(out[:si], append([]byte("UTF-8"), out[ei:]...)...)
+	}
+	return out, declared, nil
+}
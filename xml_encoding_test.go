@@ -0,0 +1,81 @@
+package fastxml
+
+import (
+	"testing"
+	"unicode/utf16"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func utf16Bytes(s string, bigEndian bool) []byte {
+	var out []byte
+	for _, u := range utf16.Encode([]rune(s)) {
+		if bigEndian {
+			out = append(out, byte(u>>8), byte(u))
+		} else {
+			out = append(out, byte(u), byte(u>>8))
+		}
+	}
+	return out
+}
+
+func Test_toUTF8(t *testing.T) {
+	tests := []struct {
+		name     string
+		in       []byte
+		want     string
+		declared string //encoding rewritten to UTF-8
+		wantErr  bool
+	}{
+		{name: "no_declaration", in: []byte(`<a>é</a>`), want: `<a>é</a>`},
+		{name: "utf8_declared", in: []byte(`<?xml version="1.0" encoding="UTF-8"?><a>é</a>`), want: `<?xml version="1.0" encoding="UTF-8"?><a>é</a>`},
+		{name: "utf8_bom", in: []byte("\xEF\xBB\xBF<a>é</a>"), want: `<a>é</a>`},
+		{name: "latin1", in: []byte("<?xml version='1.0' encoding='ISO-8859-1'?><a>caf\xE9 \x80</a>"), want: "<?xml version='1.0' encoding='UTF-8'?><a>café \u0080</a>", declared: "ISO-8859-1"},
+		{name: "windows1252", in: []byte("<?xml version=\"1.0\" encoding = \"windows-1252\"?><a>\x80 \x93q\x94 \x81</a>"), want: "<?xml version=\"1.0\" encoding = \"UTF-8\"?><a>€ “q” \u0081</a>", declared: "windows-1252"},
+		{name: "utf16le_bom", in: append([]byte{0xFF, 0xFE}, utf16Bytes(`<?xml version="1.0" encoding="UTF-16"?><a>€😀</a>`, false)...), want: `<?xml version="1.0" encoding="UTF-8"?><a>€😀</a>`, declared: "UTF-16"},
+		{name: "utf16be_bom", in: append([]byte{0xFE, 0xFF}, utf16Bytes(`<a>€</a>`, true)...), want: `<a>€</a>`},
+		{name: "utf16le_no_bom", in: utf16Bytes(`<?xml version="1.0"?><a/>`, false), want: `<?xml version="1.0"?><a/>`},
+		{name: "utf16be_no_bom", in: utf16Bytes(`<?xml version="1.0"?><a/>`, true), want: `<?xml version="1.0"?><a/>`},
+		{name: "utf16_odd_length", in: []byte{0xFF, 0xFE, '<'}, wantErr: true},
+		{name: "unsupported", in: []byte(`<?xml version="1.0" encoding="Shift_JIS"?><a/>`), wantErr: true},
+		{name: "utf16_declared_without_bom", in: []byte(`<?xml version="1.0" encoding="UTF-16"?><a/>`), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, declared, err := toUTF8(tt.in)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrUnsupportedEncoding)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, string(got))
+			assert.Equal(t, tt.declared, declared)
+		})
+	}
+}
+
+func TestXMLReaderEncoding(t *testing.T) {
+	in := []byte("<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?><Ad title=\"Caf\xE9\"><Title>R\xE9sum\xE9</Title></Ad>")
+	reader := NewXMLReader()
+	if !assert.NoError(t, reader.Parse(in)) {
+		return
+	}
+	title := reader.SelectElement(nil, "Ad", "Title")
+	if assert.NotNil(t, title) {
+		assert.Equal(t, "Résumé", reader.Text(title))
+	}
+	assert.Equal(t, "Café", reader.SelectAttrValue(reader.SelectElement(nil, "Ad"), "title", ""))
+	assert.Contains(t, string(reader.RawXML()), `encoding="UTF-8"`)
+	if decl := reader.Declaration(); assert.NotNil(t, decl) {
+		assert.Equal(t, "UTF-8", decl.Encoding.Value)
+		assert.Equal(t, string(reader.RawXML()[decl.Encoding.Start:decl.Encoding.End]), decl.Encoding.Value)
+		assert.Equal(t, "ISO-8859-1", decl.OriginalEncoding)
+	}
+
+	in = append([]byte{0xFF, 0xFE}, utf16Bytes(`<Ad><Title>€</Title></Ad>`, false)...)
+	if assert.NoError(t, reader.ParseWithXPath(in, GetXPath([][]string{{"Ad", "Title"}}))) {
+		assert.Equal(t, "€", reader.Text(reader.SelectElement(nil, "Ad", "Title")))
+	}
+
+	assert.ErrorIs(t, reader.Parse([]byte(`<?xml version="1.0" encoding="EBCDIC"?><Ad/>`)), ErrUnsupportedEncoding)
+}
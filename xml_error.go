@@ -0,0 +1,69 @@
+package fastxml
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidXML is the sentinel matched by every *SyntaxError, use errors.Is(err, ErrInvalidXML)
+var ErrInvalidXML = errors.New("invalid xml")
+
+type TokenType = xmlTokenType
+
+// SyntaxError describes where and why the input is not a valid xml document
+type SyntaxError struct {
+	Offset int       //byte offset of the offending token
+	Line   int       //1-based line number of Offset
+	Column int       //1-based column of Offset, counted in bytes
+	Token  TokenType //type of the offending token
+	Reason string    //eg: mismatched end tag </Ad> for <Wrapper>
+}
+
+func newSyntaxError(in []byte, offset int, ttype xmlTokenType, format string, args ...any) *SyntaxError {
+	if offset > len(in) {
+		offset = len(in)
+	}
+	line, column := getLineColumn(in, offset)
+	return &SyntaxError{
+		Offset: offset,
+		Line:   line,
+		Column: column,
+		Token:  ttype,
+		Reason: fmt.Sprintf(format, args...),
+	}
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s: %s at line %d, column %d (offset %d)", ErrInvalidXML.Error(), e.Reason, e.Line, e.Column, e.Offset)
+}
+
+func (e *SyntaxError) Unwrap() error {
+	return ErrInvalidXML
+}
+
+// getLineColumn returns 1-based line and column of offset, only called on error path
+func getLineColumn(in []byte, offset int) (line, column int) {
+	line = bytes.Count(in[:offset], []byte{'\n'}) + 1
+	column = offset - bytes.LastIndexByte(in[:offset], '\n')
+	return line, column
+}
+
+// unterminatedReason returns error reason for token which doesn't have closing sequence
+func unterminatedReason(ttype xmlTokenType) string {
+	switch ttype {
+	case startXMLToken:
+		return "unterminated start tag"
+	case endXMLToken:
+		return "unterminated end tag"
+	case processingXMLToken:
+		return "unterminated processing instruction"
+	case commentsXMLToken:
+		return "unterminated comment"
+	case cdataXMLToken:
+		return "unterminated CDATA section"
+	case doctypeXMLToken:
+		return "unterminated DOCTYPE"
+	}
+	return "unterminated token"
+}
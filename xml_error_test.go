@@ -0,0 +1,78 @@
+package fastxml
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyntaxError(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  SyntaxError
+	}{
+		{
+			name:  "mismatched_end_tag",
+			input: "<VAST>\n  <Wrapper>\n  </Ad>\n</VAST>",
+			want:  SyntaxError{Offset: 21, Line: 3, Column: 3, Token: endXMLToken, Reason: "mismatched end tag </Ad> for <Wrapper>"},
+		},
+		{
+			name:  "unexpected_end_tag",
+			input: "<a></a></b>",
+			want:  SyntaxError{Offset: 7, Line: 1, Column: 8, Token: endXMLToken, Reason: "unexpected end tag </b>"},
+		},
+		{
+			name:  "unterminated_comment",
+			input: "<a>\n<!-- comment </a>",
+			want:  SyntaxError{Offset: 4, Line: 2, Column: 1, Token: commentsXMLToken, Reason: "unterminated comment"},
+		},
+		{
+			name:  "comment_closer_overlaps_opener",
+			input: "<a><!--></a>",
+			want:  SyntaxError{Offset: 3, Line: 1, Column: 4, Token: commentsXMLToken, Reason: "unterminated comment"},
+		},
+		{
+			name:  "comment_closer_overlaps_opener_dash",
+			input: "<a><!---></a>",
+			want:  SyntaxError{Offset: 3, Line: 1, Column: 4, Token: commentsXMLToken, Reason: "unterminated comment"},
+		},
+		{
+			name:  "processing_instruction_closer_overlaps_opener",
+			input: "<a><?></a>",
+			want:  SyntaxError{Offset: 3, Line: 1, Column: 4, Token: processingXMLToken, Reason: "unterminated processing instruction"},
+		},
+		{
+			name:  "unterminated_cdata",
+			input: "<a><![CDATA[data</a>",
+			want:  SyntaxError{Offset: 3, Line: 1, Column: 4, Token: cdataXMLToken, Reason: "unterminated CDATA section"},
+		},
+		{
+			name:  "unterminated_start_tag",
+			input: "<root><child",
+			want:  SyntaxError{Offset: 6, Line: 1, Column: 7, Token: startXMLToken, Reason: "unterminated start tag"},
+		},
+		{
+			name:  "unclosed_element_at_eof",
+			input: "<root>\n\t<child>",
+			want:  SyntaxError{Offset: 8, Line: 2, Column: 2, Token: startXMLToken, Reason: "unclosed element <child> at EOF"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewXMLTokenizer().Parse([]byte(tt.input), nil)
+			assert.ErrorIs(t, err, ErrInvalidXML)
+
+			var syntaxErr *SyntaxError
+			if assert.True(t, errors.As(err, &syntaxErr)) {
+				assert.Equal(t, tt.want, *syntaxErr)
+			}
+		})
+	}
+}
+
+func TestSyntaxErrorString(t *testing.T) {
+	err := newSyntaxError([]byte("<a>\n</b>"), 4, endXMLToken, "mismatched end tag </%s> for <%s>", "b", "a")
+	assert.Equal(t, "invalid xml: mismatched end tag </b> for <a> at line 2, column 1 (offset 4)", err.Error())
+}
@@ -0,0 +1,142 @@
+package fastxml
+
+import (
+	"bytes"
+)
+
+// Match is element found by Extract, it references input and is valid as long as input is unchanged
+type Match struct {
+	token    XMLToken
+	in       []byte
+	entities *entityTable //entities declared in DOCTYPE, nil if not present
+}
+
+func (m *Match) Name() []byte {
+	return m.token.Name(m.in)
+}
+
+func (m *Match) XMLTag() []byte {
+	return m.token.XMLTag(m.in)
+}
+
+// TagOffset returns offsets of element in input
+func (m *Match) TagOffset() (si, ei int) {
+	return m.token.TagOffset()
+}
+
+func (m *Match) IsCDATA() bool {
+	return m.token.IsCDATA(m.in)
+}
+
+func (m *Match) RawText() []byte {
+	return m.token.Text(m.in)
+}
+
+// Text returns direct character data of element like XMLReader.Text, CDATA sections are trimmed and references decoded
+func (m *Match) Text() string {
+	if m.token.IsInline() {
+		return ""
+	}
+	buf := getBuffer()
+	defer putBuffer(buf)
+	u := unescaper{w: buf, entities: m.entities}
+	if cdata, _ := writeMixedText(&u, m.in, m.token.start.ei, m.token.end.si, false); cdata {
+		return string(trimSpaceBytes(buf.Bytes()))
+	}
+	return buf.String()
+}
+
+func (m *Match) Attributes() []Attribute {
+	m.token.Name(m.in)
+	return m.token.ParseAttribute(m.in)
+}
+
+// Attr returns raw value of attribute key
+func (m *Match) Attr(key string) ([]byte, bool) {
+	for _, attr := range m.Attributes() {
+		if bytes.Equal(attr.Key(m.in), []byte(key)) {
+			return attr.Value(m.in), true
+		}
+	}
+	return nil, false
+}
+
+// Extract returns first element at path from root, nil if not found, input after the element is not parsed
+func Extract(in []byte, path ...string) (*Match, error) {
+	return ExtractNth(in, 0, path...)
+}
+
+// ExtractNth returns n-th (0 based) element at path from root, nil if not found
+func ExtractNth(in []byte, n int, path ...string) (*Match, error) {
+	return extract(in, n, false, path)
+}
+
+// extract finds n-th element at path, startOnly stops at its start tag leaving text unavailable
+func extract(in []byte, n int, startOnly bool, path []string) (*Match, error) {
+	if len(path) == 0 || n < 0 {
+		return nil, nil
+	}
+
+	var dec Decoder
+	var parser *XMLTokenizer
+	var m *Match
+	matched := 0 //path steps matched by open elements
+	last := len(path) - 1
+
+	dec.Reset(in)
+	for dec.Next() {
+		tok := dec.Token()
+		switch tok.Kind {
+		case DOCTYPEToken:
+			parser = NewXMLTokenizer()
+			parser.entities.reset(in)
+			if err := parser.parseDoctype(in, tok.Start, tok.End); err != nil {
+				return nil, err
+			}
+		case StartXMLToken, InlineXMLToken:
+			if m != nil || tok.Depth != matched || !(path[matched] == "*" || bytes.Equal(tok.Name, []byte(path[matched]))) {
+				continue
+			}
+			if matched < last {
+				if tok.Kind == StartXMLToken {
+					matched++
+				}
+				continue
+			}
+			if n > 0 {
+				n--
+				continue
+			}
+			m = &Match{token: XMLToken{start: xmlTagIndex{si: tok.Start, ei: tok.End}}, in: in}
+			if parser != nil {
+				m.entities = &parser.entities
+			}
+			if tok.Kind == InlineXMLToken {
+				m.token.end = m.token.start
+				return m, nil
+			}
+			if startOnly {
+				return m, nil
+			}
+		case EndXMLToken:
+			if m != nil && tok.Depth == last {
+				m.token.end = xmlTagIndex{si: tok.Start, ei: tok.End}
+				return m, nil
+			}
+			if tok.Depth < matched {
+				matched = tok.Depth
+			}
+		}
+	}
+	return nil, dec.Err()
+}
+
+// ExtractAttr returns raw value of attribute key of first element at path from root
+func ExtractAttr(in []byte, key string, path ...string) ([]byte, bool, error) {
+	m, err := extract(in, 0, true, path)
+	if m == nil {
+		return nil, false, err
+	}
+	value, ok := m.Attr(key)
+	return value, ok, nil
+}
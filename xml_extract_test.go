@@ -0,0 +1,108 @@
+package fastxml
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtract(t *testing.T) {
+	in := []byte(`<VAST version="4.0"><Ad id="1"><InLine><Impression id="i1"><![CDATA[http://i1.com]]></Impression><Impression id="i2">http://i2.com?a=1&amp;b=2</Impression><Creatives><Creative/></Creatives></InLine></Ad><Ad id="2"><Wrapper><Impression id="i3">http://i3.com</Impression></Wrapper></Ad></VAST>`)
+
+	tests := []struct {
+		name   string
+		n      int
+		path   []string
+		want   string //text
+		id     string
+		tag    string
+		notNil bool
+	}{
+		{name: "first", path: []string{"VAST", "Ad", "InLine", "Impression"}, want: "http://i1.com", id: "i1", notNil: true},
+		{name: "nth", n: 1, path: []string{"VAST", "Ad", "InLine", "Impression"}, want: "http://i2.com?a=1&b=2", id: "i2", notNil: true},
+		{name: "wildcard", n: 2, path: []string{"VAST", "Ad", "*", "Impression"}, want: "http://i3.com", id: "i3", notNil: true},
+		{name: "inline", path: []string{"VAST", "Ad", "InLine", "Creatives", "Creative"}, tag: "<Creative/>", notNil: true},
+		{name: "nested", path: []string{"VAST", "Ad", "Wrapper"}, tag: `<Wrapper><Impression id="i3">http://i3.com</Impression></Wrapper>`, notNil: true},
+		{name: "not_found", n: 3, path: []string{"VAST", "Ad", "*", "Impression"}},
+		{name: "wrong_root", path: []string{"Ad"}},
+		{name: "empty_path"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := ExtractNth(in, tt.n, tt.path...)
+			assert.NoError(t, err)
+			if !tt.notNil {
+				assert.Nil(t, m)
+				return
+			}
+			if !assert.NotNil(t, m) {
+				return
+			}
+			assert.Equal(t, tt.path[len(tt.path)-1] != "*", string(m.Name()) == tt.path[len(tt.path)-1])
+			if tt.want != "" {
+				assert.Equal(t, tt.want, m.Text())
+			}
+			if tt.id != "" {
+				id, ok := m.Attr("id")
+				assert.True(t, ok)
+				assert.Equal(t, tt.id, string(id))
+			}
+			if tt.tag != "" {
+				assert.Equal(t, tt.tag, string(m.XMLTag()))
+			}
+		})
+	}
+}
+
+func TestExtractMixedText(t *testing.T) {
+	in := []byte(`<VAST><Ad><Impression> <![CDATA[http://a.com/?x=1]]>&amp;y=2<!-- c --><b>no</b><![CDATA[&z=3 ]]> </Impression></Ad></VAST>`)
+	m, err := Extract(in, "VAST", "Ad", "Impression")
+	if !assert.NoError(t, err) || !assert.NotNil(t, m) {
+		return
+	}
+	assert.Equal(t, "http://a.com/?x=1&y=2&z=3", m.Text())
+
+	//same text as XMLReader
+	reader := NewXMLReader()
+	if assert.NoError(t, reader.Parse(in)) {
+		assert.Equal(t, m.Text(), reader.Text(reader.SelectElement(nil, "VAST", "Ad", "Impression")))
+	}
+}
+
+func TestExtractStopsAtMatch(t *testing.T) {
+	//malformed content after match is never parsed
+	in := []byte(`<VAST version="3.0"><Ad><Impression>url</Impression></Ad><broken`)
+	m, err := Extract(in, "VAST", "Ad", "Impression")
+	assert.NoError(t, err)
+	if assert.NotNil(t, m) {
+		assert.Equal(t, "url", m.Text())
+		si, ei := m.TagOffset()
+		assert.Equal(t, "<Impression>url</Impression>", string(in[si:ei]))
+	}
+
+	version, ok, err := ExtractAttr(in, "version", "VAST")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "3.0", string(version))
+
+	version, ok, err = ExtractAttr([]byte(`<VAST version="4.0"/>`), "version", "VAST")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "4.0", string(version))
+
+	_, ok, err = ExtractAttr(in, "missing", "VAST")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	_, err = Extract([]byte(`<VAST><Ad></VAST>`), "VAST", "Ad", "Impression")
+	assert.ErrorIs(t, err, ErrInvalidXML)
+}
+
+func TestExtractEntities(t *testing.T) {
+	in := []byte(`<!DOCTYPE VAST [<!ENTITY host "example.com">]><VAST><Impression>http://&host;/i</Impression></VAST>`)
+	m, err := Extract(in, "VAST", "Impression")
+	assert.NoError(t, err)
+	if assert.NotNil(t, m) {
+		assert.Equal(t, "http://example.com/i", m.Text())
+	}
+}
@@ -0,0 +1,133 @@
+package fastxml
+
+// Control is returned by Handler methods to steer XMLTokenizer.ParseHandler
+type Control int
+
+const (
+	Continue     Control = iota //continue parsing
+	SkipChildren                //skip content of element, only valid from StartElement, EndElement is still reported
+	Stop                        //stop parsing without error
+)
+
+/*
+Handler receives tokens in document order while XMLTokenizer.ParseHandler runs,
+inline elements are reported by both StartElement and EndElement. Tokens are only
+valid during the call, Raw and Name share memory with input.
+*/
+type Handler interface {
+	StartElement(tok Token) Control
+	EndElement(tok Token) Control
+	Text(tok Token) Control
+	CDATA(tok Token) Control
+	Comment(tok Token) Control
+	ProcInst(tok Token) Control
+}
+
+/*
+ParseHandler parses document reporting every element, text, CDATA, comment and processing instruction to h,
+limits and StrictMode checks apply to skipped subtrees too, LenientMode recovery is not supported.
+Without StrictMode skipped subtrees are only scanned for their end tag, names inside them are not validated
+*/
+func (sp *XMLTokenizer) ParseHandler(in []byte, h Handler) error {
+	sp.ns = sp.ns[:0]
+	sp.entities.reset(in)
+	sp.elements = 0
+	sp.warnings = nil
+
+	if sp.opts.MaxDocumentSize > 0 && len(in) > sp.opts.MaxDocumentSize {
+		return &LimitError{Limit: DocumentSizeLimit, Max: sp.opts.MaxDocumentSize, Offset: sp.opts.MaxDocumentSize}
+	}
+	if sp.opts.Mode == StrictMode {
+		sp.strict.reset()
+		if err := checkChars(in); err != nil {
+			return err
+		}
+	}
+
+	dec := &sp.dec
+	dec.Reset(in)
+	skip := -1 //depth of element whose children are skipped
+	for dec.Next() {
+		tok := dec.Token()
+
+		if sp.opts.Mode == StrictMode && tok.Kind != TextToken {
+			ttype := tok.Kind
+			if ttype == InlineXMLToken {
+				ttype = StartXMLToken
+			}
+			if err := sp.checkToken(in, tok.Start, tok.End, ttype, tok.Kind == InlineXMLToken, tok.Depth); err != nil {
+				return err
+			}
+			//decoder compares local names only
+			switch tok.Kind {
+			case StartXMLToken:
+				sp.strict.open.push(tok.Start)
+			case EndXMLToken:
+				if err := checkEndTagQName(in, *sp.strict.open.pop(), tok.Start); err != nil {
+					return err
+				}
+			}
+		}
+
+		if sp.limits && (tok.Kind == StartXMLToken || tok.Kind == InlineXMLToken) {
+			token := XMLToken{start: xmlTagIndex{si: tok.Start, ei: tok.End}}
+			if err := sp.checkLimits(in, &token, tok.Depth+1); err != nil {
+				return err
+			}
+		}
+
+		if skip != -1 && tok.Depth > skip {
+			continue
+		}
+
+		var ctrl Control
+		switch tok.Kind {
+		case StartXMLToken, InlineXMLToken:
+			if ctrl = h.StartElement(tok); ctrl == Stop {
+				return nil
+			}
+			if tok.Kind == InlineXMLToken {
+				ctrl = h.EndElement(tok)
+			} else if ctrl == SkipChildren {
+				ctrl = Continue
+				if sp.opts.Mode == DefaultMode {
+					//continue with end tag of element, malformed content is tokenized to report error
+					end, err := sp.skipElement(in, tok.End, tok.Depth+1)
+					if err != nil {
+						return err
+					}
+					if end != -1 {
+						dec.index = end
+						break
+					}
+				}
+				skip = tok.Depth
+			}
+		case EndXMLToken:
+			skip = -1
+			ctrl = h.EndElement(tok)
+		case TextToken:
+			ctrl = h.Text(tok)
+		case CDATAToken:
+			ctrl = h.CDATA(tok)
+		case CommentsXMLToken:
+			ctrl = h.Comment(tok)
+		case ProcessingXMLToken:
+			ctrl = h.ProcInst(tok)
+		case DOCTYPEToken:
+			if err := sp.parseDoctype(in, tok.Start, tok.End); err != nil {
+				return err
+			}
+		}
+		if ctrl == Stop {
+			return nil
+		}
+	}
+	if err := dec.Err(); err != nil {
+		return err
+	}
+	if sp.opts.Mode == StrictMode {
+		return sp.checkEOF(in)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package fastxml
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mockHandler struct {
+	events []string
+	ctrl   map[string]Control //control returned for event
+}
+
+func (h *mockHandler) record(event string, tok Token) Control {
+	e := fmt.Sprintf("%s:%d:%s", event, tok.Depth, tok.Name)
+	if tok.Kind == TextToken || tok.Kind == CDATAToken || tok.Kind == CommentsXMLToken {
+		e = fmt.Sprintf("%s:%d:%s", event, tok.Depth, tok.Raw)
+	}
+	h.events = append(h.events, e)
+	return h.ctrl[e]
+}
+
+func (h *mockHandler) StartElement(tok Token) Control { return h.record("start", tok) }
+func (h *mockHandler) EndElement(tok Token) Control   { return h.record("end", tok) }
+func (h *mockHandler) Text(tok Token) Control         { return h.record("text", tok) }
+func (h *mockHandler) CDATA(tok Token) Control        { return h.record("cdata", tok) }
+func (h *mockHandler) Comment(tok Token) Control      { return h.record("comment", tok) }
+func (h *mockHandler) ProcInst(tok Token) Control     { return h.record("pi", tok) }
+
+func TestParseHandler(t *testing.T) {
+	in := `<?xml version="1.0"?><Ad id="1"><Wrapper><Error>e</Error></Wrapper><!--c--><Creative/><URL><![CDATA[u]]></URL></Ad>`
+
+	tests := []struct {
+		name string
+		ctrl map[string]Control
+		want []string
+	}{
+		{
+			name: "continue",
+			want: []string{
+				"pi:0:xml", "start:0:Ad",
+				"start:1:Wrapper", "start:2:Error", "text:3:e", "end:2:Error", "end:1:Wrapper",
+				"comment:1:<!--c-->", "start:1:Creative", "end:1:Creative",
+				"start:1:URL", "cdata:2:<![CDATA[u]]>", "end:1:URL", "end:0:Ad",
+			},
+		},
+		{
+			name: "skip_children",
+			ctrl: map[string]Control{"start:1:Wrapper": SkipChildren, "start:1:Creative": SkipChildren},
+			want: []string{
+				"pi:0:xml", "start:0:Ad",
+				"start:1:Wrapper", "end:1:Wrapper",
+				"comment:1:<!--c-->", "start:1:Creative", "end:1:Creative",
+				"start:1:URL", "cdata:2:<![CDATA[u]]>", "end:1:URL", "end:0:Ad",
+			},
+		},
+		{
+			name: "stop_at_start",
+			ctrl: map[string]Control{"start:2:Error": Stop},
+			want: []string{"pi:0:xml", "start:0:Ad", "start:1:Wrapper", "start:2:Error"},
+		},
+		{
+			name: "stop_at_text",
+			ctrl: map[string]Control{"text:3:e": Stop},
+			want: []string{"pi:0:xml", "start:0:Ad", "start:1:Wrapper", "start:2:Error", "text:3:e"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &mockHandler{ctrl: tt.ctrl}
+			assert.NoError(t, NewXMLTokenizer().ParseHandler([]byte(in), h))
+			assert.Equal(t, tt.want, h.events)
+		})
+	}
+}
+
+func TestParseHandlerAttributes(t *testing.T) {
+	in := []byte(`<Ad id="1"><Tracking event="start"/></Ad>`)
+	var events []string
+	h := &attrHandler{cb: func(tok Token) {
+		value, _ := tok.Attr("event")
+		events = append(events, string(tok.Name)+":"+string(value))
+	}}
+	assert.NoError(t, NewXMLTokenizer().ParseHandler(in, h))
+	assert.Equal(t, []string{"Ad:", "Tracking:start"}, events)
+}
+
+type attrHandler struct {
+	mockHandler
+	cb func(Token)
+}
+
+func (h *attrHandler) StartElement(tok Token) Control {
+	h.cb(tok)
+	return Continue
+}
+
+func TestParseHandlerErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		opts ParseOptions
+		in   string
+		err  error
+	}{
+		{name: "mismatched", in: `<a><b></a>`, err: ErrInvalidXML},
+		{name: "depth_limit_in_skipped_subtree", opts: ParseOptions{MaxDepth: 2}, in: `<a><b><c/></b></a>`, err: ErrLimitExceeded},
+		{name: "strict", opts: ParseOptions{Mode: StrictMode}, in: `<a x="1" x="2"/>`, err: ErrInvalidXML},
+		{name: "strict_prefix_mismatch", opts: ParseOptions{Mode: StrictMode}, in: `<p:x xmlns:p="u" xmlns:q="v"></q:x>`, err: ErrInvalidXML},
+		{name: "strict_names_in_skipped_subtree", opts: ParseOptions{Mode: StrictMode}, in: `<a><x></y></a>`, err: ErrInvalidXML},
+		{name: "unclosed_skipped_subtree", in: `<a><x></x>`, err: ErrInvalidXML},
+		{name: "recursive_entity", in: `<!DOCTYPE a [<!ENTITY e "&e;">]><a/>`, err: ErrInvalidXML},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &mockHandler{ctrl: map[string]Control{"start:0:a": SkipChildren}}
+			err := NewXMLTokenizerWithOptions(tt.opts).ParseHandler([]byte(tt.in), h)
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+}
+
+func TestParseHandlerSkipScan(t *testing.T) {
+	//skipped subtree is scanned for end tag without validating names inside
+	in := []byte(`<a><x></y><![CDATA[</a>]]></a><b/>`)
+	h := &mockHandler{ctrl: map[string]Control{"start:0:a": SkipChildren}}
+	assert.NoError(t, NewXMLTokenizer().ParseHandler(in, h))
+	assert.Equal(t, []string{"start:0:a", "end:0:a", "start:0:b", "end:0:b"}, h.events)
+
+	var serr *SyntaxError
+	err := NewXMLTokenizerWithOptions(ParseOptions{Mode: StrictMode}).ParseHandler([]byte(`<p:x xmlns:p="u" xmlns:q="v"></q:x>`), &mockHandler{})
+	if assert.ErrorAs(t, err, &serr) {
+		assert.Equal(t, "mismatched end tag </q:x> for <p:x>", serr.Reason)
+		assert.Equal(t, 29, serr.Offset)
+	}
+}
@@ -0,0 +1,63 @@
+package fastxml
+
+// AttrIndex maps values of attribute to elements having it, elements of each value are in document order
+type AttrIndex map[string][]*Element
+
+/*
+BuildAttrIndex indexes elements of document by value of attribute key, values are unescaped,
+key is qualified name as written in document, "id" doesn't match xlink:id attribute,
+index is built once and stays valid until next Parse, ParseOptions.IndexAttrs builds it while parsing
+*/
+func (xr *XMLReader) BuildAttrIndex(key string) AttrIndex {
+	if index, ok := xr.indexes[key]; ok {
+		return index
+	}
+	xr.buildAttrIndexes([]string{key})
+	return xr.indexes[key]
+}
+
+// SelectElementsByAttr returns elements with attribute key having value, index of key is built on first use
+func (xr *XMLReader) SelectElementsByAttr(key, value string) []*Element {
+	return xr.BuildAttrIndex(key)[value]
+}
+
+// SelectElementByAttr returns first element with attribute key having value, index of key is built on first use
+func (xr *XMLReader) SelectElementByAttr(key, value string) *Element {
+	if elements := xr.SelectElementsByAttr(key, value); len(elements) != 0 {
+		return elements[0]
+	}
+	return nil
+}
+
+// buildAttrIndexes indexes all not yet indexed keys in single pass over document
+func (xr *XMLReader) buildAttrIndexes(keys []string) {
+	if xr.indexes == nil {
+		xr.indexes = make(map[string]AttrIndex, len(keys))
+	}
+	pending := make(map[string]AttrIndex, len(keys))
+	for _, key := range keys {
+		if _, ok := xr.indexes[key]; !ok {
+			pending[key] = AttrIndex{}
+			xr.indexes[key] = pending[key]
+		}
+	}
+	if len(pending) == 0 || len(xr.tree.nodes) == 0 {
+		return
+	}
+
+	xr.tree.traverse(nil, func(node *Element) {
+		if node.idx == 0 {
+			return //document node
+		}
+		for _, attr := range node.data.ParseAttribute(xr.in) {
+			index, ok := pending[string(attr.QName(xr.in))]
+			if !ok {
+				continue
+			}
+			value := xr.AttrValue(&attr)
+			if elements := index[value]; len(elements) == 0 || elements[len(elements)-1] != node {
+				index[value] = append(elements, node)
+			}
+		}
+	})
+}
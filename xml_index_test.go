@@ -0,0 +1,87 @@
+package fastxml
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var indexTestXML = []byte(`<VAST>
+	<Ad id="pod-1" sequence="1"><InLine><Creatives>
+		<Creative id="c1" dup="a" dup="b"><Linear/></Creative>
+		<Creative id="c2"/>
+	</Creatives></InLine></Ad>
+	<Ad id="pod-2" sequence="2"><Wrapper><Creatives><Creative id="c1"/><Creative id="a&amp;b"/></Creatives></Wrapper></Ad>
+</VAST>`)
+
+func TestXMLReaderBuildAttrIndex(t *testing.T) {
+	reader := NewXMLReader()
+	if !assert.NoError(t, reader.Parse(indexTestXML)) {
+		return
+	}
+
+	index := reader.BuildAttrIndex("id")
+	assert.Len(t, index, 5)
+	assert.Equal(t, reader.FindFirstDescendant(nil, "Ad"), index["pod-1"][0])
+	if creatives := index["c1"]; assert.Len(t, creatives, 2) {
+		//document order
+		assert.Equal(t, "InLine", reader.Name(reader.Parent(reader.Parent(creatives[0]))))
+		assert.Equal(t, "Wrapper", reader.Name(reader.Parent(reader.Parent(creatives[1]))))
+	}
+	assert.Len(t, index["a&b"], 1)
+	assert.Empty(t, index["missing"])
+
+	//cached until next parse
+	assert.Equal(t, index, reader.BuildAttrIndex("id"))
+	assert.Equal(t, "2", reader.SelectAttrValue(reader.SelectElementByAttr("id", "pod-2"), "sequence", ""))
+	assert.Len(t, reader.SelectElementsByAttr("sequence", "1"), 1)
+	assert.Nil(t, reader.SelectElementByAttr("id", "missing"))
+	assert.Nil(t, reader.SelectElementByAttr("missing", "c1"))
+
+	//element is indexed once for duplicated attribute
+	dup := reader.BuildAttrIndex("dup")
+	assert.Len(t, dup["a"], 1)
+	assert.Len(t, dup["b"], 1)
+
+	if !assert.NoError(t, reader.Parse([]byte(`<VAST><Ad id="pod-3"/></VAST>`))) {
+		return
+	}
+	assert.Empty(t, reader.SelectElementsByAttr("id", "pod-1"))
+	assert.Len(t, reader.SelectElementsByAttr("id", "pod-3"), 1)
+}
+
+func TestXMLReaderBuildAttrIndexQualifiedNames(t *testing.T) {
+	reader := NewXMLReader()
+	in := []byte(`<VAST xmlns:xlink="http://www.w3.org/1999/xlink"><Ad id="x"/><Ad xlink:id="x"/><Ad xlink:id="y" id="z"/></VAST>`)
+	if !assert.NoError(t, reader.Parse(in)) {
+		return
+	}
+
+	ads := reader.SelectElements(nil, "VAST", "Ad")
+	assert.Equal(t, []*Element{ads[0]}, reader.SelectElementsByAttr("id", "x"))
+	assert.Equal(t, []*Element{ads[1]}, reader.SelectElementsByAttr("xlink:id", "x"))
+	assert.Equal(t, ads[2], reader.SelectElementByAttr("xlink:id", "y"))
+	assert.Nil(t, reader.SelectElementByAttr("id", "y"))
+	assert.Equal(t, ads[2], reader.SelectElementByAttr("id", "z"))
+}
+
+func TestXMLReaderIndexAttrsOption(t *testing.T) {
+	reader := NewXMLReaderWithOptions(ParseOptions{IndexAttrs: []string{"id", "sequence"}})
+	if !assert.NoError(t, reader.Parse(indexTestXML)) {
+		return
+	}
+	assert.Len(t, reader.indexes, 2)
+	assert.Len(t, reader.indexes["id"]["c1"], 2)
+	assert.Len(t, reader.indexes["sequence"], 2)
+
+	//xpath mode indexes only reported elements
+	if !assert.NoError(t, reader.ParseWithXPath(indexTestXML, GetXPath([][]string{{"VAST", "Ad"}}))) {
+		return
+	}
+	assert.Len(t, reader.indexes["id"], 2)
+	assert.Empty(t, reader.indexes["id"]["c1"])
+
+	//failed parse leaves no index
+	assert.Error(t, reader.Parse([]byte(`<VAST><Ad id="x"></VAST>`)))
+	assert.Nil(t, reader.indexes)
+}
@@ -0,0 +1,75 @@
+package fastxml
+
+import (
+	"bytes"
+)
+
+const (
+	xmlNamespaceURI   = "http://www.w3.org/XML/1998/namespace"
+	xmlnsNamespaceURI = "http://www.w3.org/2000/xmlns/"
+)
+
+var xmlnsPrefix = []byte("xmlns")
+
+// nsBinding is xmlns declaration, bindings are chained to the bindings of enclosing elements
+type nsBinding struct {
+	prefix, uri xmlTagIndex
+	parent      int //scope of enclosing bindings, 0 if none
+}
+
+// scope returns namespace scope of token, registering xmlns declarations of its start tag, xmlns is set by scanStartTag
+func (sp *XMLTokenizer) scope(in []byte, token *XMLToken, parent *Element, xmlns bool) int {
+	scope := 0
+	if parent != nil {
+		scope = parent.data.scope
+	}
+	if !xmlns {
+		return scope
+	}
+
+	token.Name(in)
+	//NOTE: token end is not known yet, trailing '/' of inline token is ignored by parseAttributes
+	for si := token.name.ei; ; {
+		attr, found := nextAttribute(in, si, token.start.ei-1)
+		if !found {
+			break
+		}
+		si = attr.value.ei + 1
+
+		binding := nsBinding{uri: attr.value, parent: scope}
+		if prefix := attr.Prefix(in); len(prefix) == 0 {
+			//default namespace xmlns="uri"
+			if !bytes.Equal(attr.Key(in), xmlnsPrefix) {
+				continue
+			}
+		} else if bytes.Equal(prefix, xmlnsPrefix) {
+			//prefixed namespace xmlns:prefix="uri"
+			binding.prefix = attr.key
+		} else {
+			continue
+		}
+		sp.ns = append(sp.ns, binding)
+		scope = len(sp.ns)
+	}
+	return scope
+}
+
+// lookupNamespace returns uri bound to prefix in given scope
+func (sp *XMLTokenizer) lookupNamespace(in []byte, scope int, prefix []byte) ([]byte, bool) {
+	for scope != 0 {
+		binding := sp.ns[scope-1]
+		if bytes.Equal(in[binding.prefix.si:binding.prefix.ei], prefix) {
+			return in[binding.uri.si:binding.uri.ei], true
+		}
+		scope = binding.parent
+	}
+	switch string(prefix) {
+	case "":
+		return nil, true //no default namespace
+	case "xml":
+		return []byte(xmlNamespaceURI), true
+	case "xmlns":
+		return []byte(xmlnsNamespaceURI), true
+	}
+	return nil, false
+}
@@ -0,0 +1,74 @@
+package fastxml
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNamespaceURI(t *testing.T) {
+	in := []byte(`<root xmlns="urn:default" xmlns:a="urn:a" xmlns:b="urn:b">
+	<a:Ad id="1" b:ref="x" xml:lang="en"/>
+	<b:Ad id="2"/>
+	<Ad id="3"/>
+	<inner xmlns:a="urn:other" xmlns="">
+		<a:Ad id="4"/>
+		<Ad id="5"/>
+	</inner>
+</root>`)
+
+	reader := NewXMLReader()
+	if !assert.NoError(t, reader.Parse(in)) {
+		return
+	}
+
+	root := reader.SelectElement(nil, "root")
+	assert.Equal(t, "urn:default", reader.NamespaceURI(root))
+
+	tests := []struct {
+		parent   *Element
+		uri      string
+		expected []string
+	}{
+		{parent: root, uri: "urn:a", expected: []string{"1"}},
+		{parent: root, uri: "urn:b", expected: []string{"2"}},
+		{parent: root, uri: "urn:default", expected: []string{"3"}},
+		{parent: reader.SelectElement(root, "inner"), uri: "urn:other", expected: []string{"4"}},
+		{parent: reader.SelectElement(root, "inner"), uri: "", expected: []string{"5"}},
+		{parent: reader.SelectElement(root, "inner"), uri: "urn:a", expected: nil},
+	}
+	for _, tt := range tests {
+		var actual []string
+		for _, element := range reader.SelectElementsNS(tt.parent, tt.uri, "Ad") {
+			actual = append(actual, reader.SelectAttrValue(element, "id", ""))
+		}
+		assert.Equal(t, tt.expected, actual, "uri:%s", tt.uri)
+	}
+
+	ad := reader.SelectElementNS(root, "urn:b", "Ad")
+	assert.Equal(t, "b:Ad", reader.NSName(ad))
+	assert.Nil(t, reader.SelectElementNS(root, "urn:missing", "Ad"))
+
+	ad = reader.SelectElementNS(root, "urn:a", "Ad")
+	if attr := reader.SelectAttrNS(ad, "urn:b", "ref"); assert.NotNil(t, attr) {
+		assert.Equal(t, "x", string(attr.Value(in)))
+		assert.Equal(t, "b", string(attr.Prefix(in)))
+	}
+	if attr := reader.SelectAttrNS(ad, xmlNamespaceURI, "lang"); assert.NotNil(t, attr) {
+		assert.Equal(t, "en", string(attr.Value(in)))
+	}
+	assert.NotNil(t, reader.SelectAttrNS(ad, "", "id"))
+	assert.Nil(t, reader.SelectAttrNS(ad, "urn:a", "id"))
+}
+
+func TestRemoveNamespacedAttribute(t *testing.T) {
+	in := []byte(`<a xmlns:xs="urn:xs" xs:k="v" k="v"></a>`)
+	reader := NewXMLReader()
+	if !assert.NoError(t, reader.Parse(in)) {
+		return
+	}
+	a := reader.SelectElement(nil, "a")
+	updater := NewXMLUpdater(reader, WriteSettings{})
+	updater.RemoveAttribute(reader.SelectAttrNS(a, "urn:xs", "k"))
+	assert.Equal(t, `<a xmlns:xs="urn:xs" k="v"></a>`, updater.String())
+}
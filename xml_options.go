@@ -0,0 +1,125 @@
+package fastxml
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ParseMode controls how parser reacts on malformed documents
+type ParseMode int
+
+const (
+	DefaultMode ParseMode = iota //fail on first unclosed, stray or mismatched tag
+	LenientMode                  //recover from unclosed, stray or mismatched tags and report warnings, auto-closed elements end where they were closed
+	StrictMode                   //check all XML 1.0 well-formedness constraints
+)
+
+// ParseOptions guards parser against untrusted input, zero value of any limit means no limit
+type ParseOptions struct {
+	Mode ParseMode
+
+	MaxDepth        int //max nesting of elements
+	MaxElements     int //max number of elements in document
+	MaxAttributes   int //max number of attributes per element
+	MaxNameLength   int //max length of element or attribute name including namespace prefix
+	MaxDocumentSize int //max size of document in bytes
+
+	IndexAttrs []string //attributes indexed by XMLReader after each parse, see XMLReader.BuildAttrIndex
+	CharData   bool     //report text, CDATA, comment and processing instruction nodes, needed by XMLReader.Comments and XPath node tests
+}
+
+func (o ParseOptions) hasLimits() bool {
+	return o.MaxDepth > 0 || o.MaxElements > 0 || o.MaxAttributes > 0 || o.MaxNameLength > 0 || o.MaxDocumentSize > 0
+}
+
+// ErrLimitExceeded is the sentinel matched by every *LimitError, use errors.Is(err, ErrLimitExceeded)
+var ErrLimitExceeded = errors.New("parser limit exceeded")
+
+type Limit int
+
+const (
+	DepthLimit Limit = iota + 1
+	ElementsLimit
+	AttributesLimit
+	NameLengthLimit
+	DocumentSizeLimit
+)
+
+func (l Limit) String() string {
+	switch l {
+	case DepthLimit:
+		return "MaxDepth"
+	case ElementsLimit:
+		return "MaxElements"
+	case AttributesLimit:
+		return "MaxAttributes"
+	case NameLengthLimit:
+		return "MaxNameLength"
+	case DocumentSizeLimit:
+		return "MaxDocumentSize"
+	}
+	return "UnknownLimit"
+}
+
+// LimitError is returned when document exceeds one of the ParseOptions limits
+type LimitError struct {
+	Limit  Limit //exceeded limit
+	Max    int   //configured value of limit
+	Offset int   //byte offset of token exceeding the limit
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s: %s %d at offset %d", ErrLimitExceeded.Error(), e.Limit, e.Max, e.Offset)
+}
+
+func (e *LimitError) Unwrap() error {
+	return ErrLimitExceeded
+}
+
+// warn records recovery done in lenient mode
+func (sp *XMLTokenizer) warn(in []byte, offset int, ttype xmlTokenType, format string, args ...any) {
+	sp.warnings = append(sp.warnings, *newSyntaxError(in, offset, ttype, format, args...))
+}
+
+// checkLimits validates start or inline element token against configured limits
+func (sp *XMLTokenizer) checkLimits(in []byte, token *XMLToken, depth int) error {
+	opts := &sp.opts
+	sp.elements++
+
+	if opts.MaxDepth > 0 && depth > opts.MaxDepth {
+		return &LimitError{Limit: DepthLimit, Max: opts.MaxDepth, Offset: token.start.si}
+	}
+	if opts.MaxElements > 0 && sp.elements > opts.MaxElements {
+		return &LimitError{Limit: ElementsLimit, Max: opts.MaxElements, Offset: token.start.si}
+	}
+
+	token.Name(in)
+	if opts.MaxNameLength > 0 && token.name.ei-token.start.si-1 > opts.MaxNameLength {
+		return &LimitError{Limit: NameLengthLimit, Max: opts.MaxNameLength, Offset: token.start.si}
+	}
+
+	if opts.MaxAttributes <= 0 && opts.MaxNameLength <= 0 {
+		return nil
+	}
+	count := 0
+	for si := token.name.ei; ; {
+		attr, found := nextAttribute(in, si, token.start.ei-1)
+		if !found {
+			break
+		}
+		if count++; opts.MaxAttributes > 0 && count > opts.MaxAttributes {
+			return &LimitError{Limit: AttributesLimit, Max: opts.MaxAttributes, Offset: attr.key.si}
+		}
+		if opts.MaxNameLength > 0 {
+			length := attr.key.ei - attr.key.si
+			if prefix := attr.Prefix(in); len(prefix) > 0 {
+				length += len(prefix) + 1
+			}
+			if length > opts.MaxNameLength {
+				return &LimitError{Limit: NameLengthLimit, Max: opts.MaxNameLength, Offset: attr.key.si}
+			}
+		}
+		si = attr.value.ei + 1
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+package fastxml
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseOptionsLimits(t *testing.T) {
+	in := `<a><b id="1" x:y="2"/><c><d>text</d></c><long-name/></a>`
+
+	tests := []struct {
+		name    string
+		opts    ParseOptions
+		limit   Limit
+		offset  int
+		wantErr bool
+	}{
+		{name: "no_limits", opts: ParseOptions{}},
+		{name: "within_limits", opts: ParseOptions{MaxDepth: 3, MaxElements: 5, MaxAttributes: 2, MaxNameLength: 9, MaxDocumentSize: len(in)}},
+		{name: "depth", opts: ParseOptions{MaxDepth: 2}, limit: DepthLimit, offset: 25, wantErr: true},
+		{name: "elements", opts: ParseOptions{MaxElements: 4}, limit: ElementsLimit, offset: 40, wantErr: true},
+		{name: "attributes", opts: ParseOptions{MaxAttributes: 1}, limit: AttributesLimit, offset: 15, wantErr: true},
+		{name: "element_name", opts: ParseOptions{MaxNameLength: 8}, limit: NameLengthLimit, offset: 40, wantErr: true},
+		{name: "attribute_name", opts: ParseOptions{MaxNameLength: 2}, limit: NameLengthLimit, offset: 15, wantErr: true},
+		{name: "document_size", opts: ParseOptions{MaxDocumentSize: 10}, limit: DocumentSizeLimit, offset: 10, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewXMLReaderWithOptions(tt.opts).Parse([]byte(in))
+			if !tt.wantErr {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, ErrLimitExceeded)
+			assert.NotErrorIs(t, err, ErrInvalidXML)
+			var lerr *LimitError
+			if assert.True(t, errors.As(err, &lerr)) {
+				assert.Equal(t, tt.limit, lerr.Limit)
+				assert.Equal(t, tt.offset, lerr.Offset)
+			}
+		})
+	}
+}
+
+func TestParseOptionsReuse(t *testing.T) {
+	parser := NewXMLTokenizerWithOptions(ParseOptions{MaxElements: 2})
+	in := []byte(`<a><b/></a>`)
+	assert.NoError(t, parser.Parse(in, nil))
+	assert.NoError(t, parser.Parse(in, nil), "element count must reset between documents")
+}
+
+func TestLimitError(t *testing.T) {
+	err := &LimitError{Limit: DepthLimit, Max: 10, Offset: 42}
+	assert.Equal(t, "parser limit exceeded: MaxDepth 10 at offset 42", err.Error())
+	assert.Equal(t, "UnknownLimit", Limit(0).String())
+}
+
+func TestLenientMode(t *testing.T) {
+	tests := []struct {
+		name     string
+		in       string
+		want     string //name:xml of all elements in post order
+		warnings []string
+	}{
+		{
+			name: "well_formed",
+			in:   `<a><b>1</b></a>`,
+			want: `b:<b>1</b>|a:<a><b>1</b></a>|`,
+		},
+		{
+			name:     "unclosed_at_eof",
+			in:       `<a><b>1</b><c>2`,
+			want:     `b:<b>1</b>|c:<c>2|a:<a><b>1</b><c>2|`,
+			warnings: []string{"auto-closed unclosed element <c> at EOF", "auto-closed unclosed element <a> at EOF"},
+		},
+		{
+			name:     "stray_end_tag",
+			in:       `<a></x><b/></a></a>`,
+			want:     `b:<b/>|a:<a></x><b/></a>|`,
+			warnings: []string{"ignored stray end tag </x>", "ignored stray end tag </a>"},
+		},
+		{
+			name:     "intermediate_elements",
+			in:       `<a><b><c>1</a><d/>`,
+			want:     `c:<c>1|b:<b><c>1|a:<a><b><c>1</a>|d:<d/>|`,
+			warnings: []string{"auto-closed <c> at </a>", "auto-closed <b> at </a>"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := NewXMLReaderWithOptions(ParseOptions{Mode: LenientMode})
+			in := []byte(tt.in)
+			if !assert.NoError(t, reader.Parse(in)) {
+				return
+			}
+			got := ""
+			reader.Iterate(func(e *Element) {
+				if e.Index() == 0 {
+					return //document node
+				}
+				got += reader.Name(e) + ":" + string(reader.XMLTag(e)) + "|"
+			})
+			assert.Equal(t, tt.want, got)
+
+			var warnings []string
+			for _, w := range reader.Warnings() {
+				warnings = append(warnings, w.Reason)
+			}
+			assert.Equal(t, tt.warnings, warnings)
+		})
+	}
+}
+
+func TestLenientModeReader(t *testing.T) {
+	reader := NewXMLReaderWithOptions(ParseOptions{Mode: LenientMode})
+	in := []byte(`<VAST><Ad id="1"><Title>Ad &amp; more</Ad><Empty></VAST>`)
+	if !assert.NoError(t, reader.Parse(in)) {
+		return
+	}
+	title := reader.SelectElement(nil, "VAST", "Ad", "Title")
+	if assert.NotNil(t, title) {
+		assert.Equal(t, "Ad & more", reader.Text(title))
+		assert.False(t, title.Data().IsInline())
+	}
+	ad := reader.SelectElement(nil, "VAST", "Ad")
+	if assert.NotNil(t, ad) {
+		assert.Equal(t, "1", reader.SelectAttrValue(ad, "id", ""))
+	}
+	empty := reader.SelectElement(nil, "VAST", "Empty")
+	if assert.NotNil(t, empty) {
+		assert.False(t, empty.Data().IsInline())
+		assert.Equal(t, "", reader.Text(empty))
+	}
+	warnings := reader.Warnings()
+	if assert.Len(t, warnings, 2) {
+		assert.Equal(t, 17, warnings[0].Offset)
+	}
+
+	//warnings of previous document are not overwritten by next parse
+	if assert.NoError(t, reader.Parse([]byte(`<a><b></a>`))) {
+		assert.Len(t, reader.Warnings(), 1)
+		assert.Equal(t, "auto-closed <Title> at </Ad>", warnings[0].Reason)
+	}
+
+	//default mode still fails
+	assert.ErrorIs(t, NewXMLReader().Parse(in), ErrInvalidXML)
+}
+
+func TestLenientModeUpdater(t *testing.T) {
+	tests := []struct {
+		name   string
+		in     string
+		update func(*XMLReader, *XMLUpdater)
+		want   string
+	}{
+		{
+			name: "append_unclosed_at_eof",
+			in:   `<a><b>1</b><c>2`,
+			update: func(xr *XMLReader, xu *XMLUpdater) {
+				xu.AppendElement(xr.SelectElement(nil, "a", "c"), NewElement("x"))
+			},
+			want: `<a><b>1</b><c>2<x></x>`,
+		},
+		{
+			name: "text_unclosed_at_eof",
+			in:   `<a><b>1</b><c>2`,
+			update: func(xr *XMLReader, xu *XMLUpdater) {
+				xu.UpdateText(xr.SelectElement(nil, "a", "c"), "3", false, NoEscaping)
+			},
+			want: `<a><b>1</b><c>3`,
+		},
+		{
+			name: "append_auto_closed_at_ancestor_end",
+			in:   `<a><b><c>1</a><d/>`,
+			update: func(xr *XMLReader, xu *XMLUpdater) {
+				xu.AppendElement(xr.SelectElement(nil, "a", "b"), NewElement("x"))
+			},
+			want: `<a><b><c>1<x></x></a><d/>`,
+		},
+		{
+			name: "text_auto_closed_at_ancestor_end",
+			in:   `<a><b><c>1</a><d/>`,
+			update: func(xr *XMLReader, xu *XMLUpdater) {
+				xu.UpdateText(xr.SelectElement(nil, "a", "b", "c"), "2", true, NoEscaping)
+			},
+			want: `<a><b><c><![CDATA[2]]></a><d/>`,
+		},
+		{
+			name: "text_of_ancestor",
+			in:   `<a><b><c>1</a><d/>`,
+			update: func(xr *XMLReader, xu *XMLUpdater) {
+				xu.UpdateText(xr.SelectElement(nil, "a"), "2", false, NoEscaping)
+			},
+			want: `<a>2</a><d/>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := NewXMLReaderWithOptions(ParseOptions{Mode: LenientMode})
+			if !assert.NoError(t, reader.Parse([]byte(tt.in))) {
+				return
+			}
+			updater := NewXMLUpdater(reader, WriteSettings{})
+			tt.update(reader, updater)
+			assert.Equal(t, tt.want, updater.String())
+		})
+	}
+}
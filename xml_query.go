@@ -0,0 +1,491 @@
+package fastxml
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrInvalidXPath is the sentinel matched by every *XPathError, use errors.Is(err, ErrInvalidXPath)
+var ErrInvalidXPath = errors.New("invalid xpath")
+
+// XPathError is returned by CompileXPath for expressions outside of supported XPath 1.0 subset
+type XPathError struct {
+	Expr   string
+	Offset int //byte offset of the offending token in Expr
+	Reason string
+}
+
+func (e *XPathError) Error() string {
+	return fmt.Sprintf("%s: %s at offset %d in %q", ErrInvalidXPath.Error(), e.Reason, e.Offset, e.Expr)
+}
+
+func (e *XPathError) Unwrap() error {
+	return ErrInvalidXPath
+}
+
+/*
+XPathExpr is compiled XPath 1.0 expression, it doesn't refer any document and can be shared
+between readers and goroutines, supported subset is
+
+	axes:      child, descendant, descendant-or-self, parent, self, attribute and abbreviations / // . .. @
+	tests:     name, prefix:name, prefix:*, *, node(), text(), comment(), processing-instruction()
+	operators: or and = != < <= > >= + - * div mod | and unary -
+	functions: core node set, string, boolean and number functions, variables are not supported
+
+name without prefix matches local name of element or attribute regardless of its namespace prefix,
+text(), comment() and processing-instruction() select nodes only if document is parsed with ParseOptions.CharData
+*/
+type XPathExpr struct {
+	expr string
+	root queryExpr
+}
+
+// CompileXPath compiles expression to be evaluated by XMLReader.QueryXPath or XMLReader.EvaluateXPath
+func CompileXPath(expr string) (*XPathExpr, error) {
+	tokens, err := lexQuery(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &queryParser{expr: expr, tokens: tokens}
+	root, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != queryEOF {
+		return nil, p.errorf(tok, "unexpected %q", tok.text)
+	}
+	return &XPathExpr{expr: expr, root: root}, nil
+}
+
+// MustCompileXPath is like CompileXPath but panics on invalid expression
+func MustCompileXPath(expr string) *XPathExpr {
+	x, err := CompileXPath(expr)
+	if err != nil {
+		panic(err)
+	}
+	return x
+}
+
+// String returns source of expression
+func (x *XPathExpr) String() string {
+	return x.expr
+}
+
+type queryTokenKind int
+
+const (
+	queryEOF      queryTokenKind = iota
+	queryName                    //name test, function, axis or node type name
+	queryNumber                  //1, 1.5, .5
+	queryLiteral                 //'text' or "text", quotes are not part of token text
+	queryOperator                //and or div mod * / // | + - = != < <= > >=
+	querySymbol                  //( ) [ ] . .. @ , ::
+)
+
+type queryToken struct {
+	kind   queryTokenKind
+	text   string
+	offset int
+}
+
+// lexQuery splits expression into tokens, * and names are read as operators only after operand as per XPath 1.0 lexical rules
+func lexQuery(expr string) ([]queryToken, error) {
+	var tokens []queryToken
+	for i := 0; ; {
+		for i < len(expr) && whitespace[expr[i]] {
+			i++
+		}
+		if i == len(expr) {
+			return append(tokens, queryToken{kind: queryEOF, offset: i}), nil
+		}
+
+		operand := false //previous token ends an operand
+		if n := len(tokens); n > 0 {
+			prev := tokens[n-1]
+			operand = prev.kind != queryOperator && !(prev.kind == querySymbol && strings.Contains("@ :: ( [ ,", prev.text))
+		}
+
+		tok := queryToken{offset: i}
+		switch ch := expr[i]; {
+		case ch == '"' || ch == '\'':
+			end := strings.IndexByte(expr[i+1:], ch)
+			if end == -1 {
+				return nil, &XPathError{Expr: expr, Offset: i, Reason: "unterminated string literal"}
+			}
+			tok.kind, tok.text = queryLiteral, expr[i+1:i+1+end]
+			i += end + 2
+		case num[ch] || ch == '.' && i+1 < len(expr) && num[expr[i+1]]:
+			end := i
+			for end < len(expr) && num[expr[end]] {
+				end++
+			}
+			if end < len(expr) && expr[end] == '.' {
+				for end++; end < len(expr) && num[expr[end]]; end++ {
+				}
+			}
+			tok.kind, tok.text = queryNumber, expr[i:end]
+			i = end
+		case ch == '*' && operand:
+			tok.kind, tok.text = queryOperator, "*"
+			i++
+		case ch == '*':
+			tok.kind, tok.text = queryName, "*"
+			i++
+		case ch == '/' || ch == '|' || ch == '+' || ch == '-' || ch == '=' || ch == '!' || ch == '<' || ch == '>':
+			end := i + 1
+			if end < len(expr) && (ch == '/' && expr[end] == '/' || ch != '/' && ch != '|' && ch != '+' && ch != '-' && ch != '=' && expr[end] == '=') {
+				end++
+			}
+			if expr[i:end] == "!" {
+				return nil, &XPathError{Expr: expr, Offset: i, Reason: "unexpected '!'"}
+			}
+			tok.kind, tok.text = queryOperator, expr[i:end]
+			i = end
+		case ch == '.' || ch == ':':
+			end := i + 1
+			if end < len(expr) && expr[end] == ch {
+				end++
+			}
+			if expr[i:end] == ":" {
+				return nil, &XPathError{Expr: expr, Offset: i, Reason: "unexpected ':'"}
+			}
+			tok.kind, tok.text = querySymbol, expr[i:end]
+			i = end
+		case ch == '(' || ch == ')' || ch == '[' || ch == ']' || ch == '@' || ch == ',':
+			tok.kind, tok.text = querySymbol, expr[i:i+1]
+			i++
+		case ch == '$':
+			return nil, &XPathError{Expr: expr, Offset: i, Reason: "variables are not supported"}
+		default:
+			end := scanQueryName(expr, i)
+			if end == i {
+				r, _ := utf8.DecodeRuneInString(expr[i:])
+				return nil, &XPathError{Expr: expr, Offset: i, Reason: fmt.Sprintf("unexpected character %q", r)}
+			}
+			//prefix:name or prefix:*
+			if end+1 < len(expr) && expr[end] == ':' && expr[end+1] != ':' {
+				if expr[end+1] == '*' {
+					end += 2
+				} else if e := scanQueryName(expr, end+1); e > end+1 {
+					end = e
+				}
+			}
+			tok.kind, tok.text = queryName, expr[i:end]
+			if operand && (tok.text == "and" || tok.text == "or" || tok.text == "div" || tok.text == "mod") {
+				tok.kind = queryOperator
+			}
+			i = end
+		}
+		tokens = append(tokens, tok)
+	}
+}
+
+// scanQueryName returns end index of NCName starting at expr[i], i if there is no name
+func scanQueryName(expr string, i int) int {
+	for start := i; i < len(expr); {
+		r, size := utf8.DecodeRuneInString(expr[i:])
+		if i == start && !isNameStartRune(r) || i > start && !isNameRune(r) {
+			break
+		}
+		i += size
+	}
+	return i
+}
+
+type queryParser struct {
+	expr   string
+	tokens []queryToken
+	i      int
+}
+
+func (p *queryParser) peek() queryToken {
+	return p.tokens[p.i]
+}
+
+// peekAt returns token following current token by n
+func (p *queryParser) peekAt(n int) queryToken {
+	if p.i+n >= len(p.tokens) {
+		return p.tokens[len(p.tokens)-1]
+	}
+	return p.tokens[p.i+n]
+}
+
+// accept consumes current token if it matches kind and text
+func (p *queryParser) accept(kind queryTokenKind, text string) bool {
+	if tok := p.peek(); tok.kind == kind && tok.text == text {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *queryParser) expect(kind queryTokenKind, text string) error {
+	if !p.accept(kind, text) {
+		tok := p.peek()
+		if tok.kind == queryEOF {
+			return p.errorf(tok, "missing %q", text)
+		}
+		return p.errorf(tok, "expected %q instead of %q", text, tok.text)
+	}
+	return nil
+}
+
+func (p *queryParser) errorf(tok queryToken, format string, args ...any) error {
+	return &XPathError{Expr: p.expr, Offset: tok.offset, Reason: fmt.Sprintf(format, args...)}
+}
+
+func (p *queryParser) parseExpr() (queryExpr, error) {
+	return p.parseOr()
+}
+
+// parseBinary parses left associative operators of same precedence
+func (p *queryParser) parseBinary(next func() (queryExpr, error), ops ...string) (queryExpr, error) {
+	left, err := next()
+	for err == nil {
+		tok := p.peek()
+		if tok.kind != queryOperator || !containsString(ops, tok.text) {
+			break
+		}
+		p.i++
+		var right queryExpr
+		if right, err = next(); err == nil {
+			left = &queryBinary{op: tok.text, left: left, right: right}
+		}
+	}
+	return left, err
+}
+
+func (p *queryParser) parseOr() (queryExpr, error) {
+	return p.parseBinary(p.parseAnd, "or")
+}
+
+func (p *queryParser) parseAnd() (queryExpr, error) {
+	return p.parseBinary(p.parseEquality, "and")
+}
+
+func (p *queryParser) parseEquality() (queryExpr, error) {
+	return p.parseBinary(p.parseRelational, "=", "!=")
+}
+
+func (p *queryParser) parseRelational() (queryExpr, error) {
+	return p.parseBinary(p.parseAdditive, "<", "<=", ">", ">=")
+}
+
+func (p *queryParser) parseAdditive() (queryExpr, error) {
+	return p.parseBinary(p.parseMultiplicative, "+", "-")
+}
+
+func (p *queryParser) parseMultiplicative() (queryExpr, error) {
+	return p.parseBinary(p.parseUnary, "*", "div", "mod")
+}
+
+func (p *queryParser) parseUnary() (queryExpr, error) {
+	if p.accept(queryOperator, "-") {
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &queryNegate{expr: expr}, nil
+	}
+	return p.parseBinary(p.parsePath, "|")
+}
+
+// parsePath parses location path or filter expression optionally followed by relative location path
+func (p *queryParser) parsePath() (queryExpr, error) {
+	path := &queryPath{}
+	sep := false //separator is expected before next step
+	tok := p.peek()
+	switch {
+	case p.accept(queryOperator, "/"):
+		path.absolute = true
+		if !p.isStepStart() {
+			return path, nil //document node
+		}
+	case tok.kind == queryOperator && tok.text == "//":
+		path.absolute, sep = true, true
+	case tok.kind == queryLiteral || tok.kind == queryNumber || tok.kind == querySymbol && tok.text == "(" || p.isFunctionCall():
+		filter, err := p.parseFilter()
+		if err != nil {
+			return nil, err
+		}
+		if next := p.peek(); next.kind != queryOperator || next.text != "/" && next.text != "//" {
+			return filter, nil
+		}
+		path.filter, sep = filter, true
+	}
+
+	for {
+		if sep {
+			if p.accept(queryOperator, "//") {
+				path.steps = append(path.steps, &queryStep{axis: queryDescendantOrSelfAxis, test: queryNodeTest{kind: queryAnyNode}})
+			} else if !p.accept(queryOperator, "/") {
+				return path, nil
+			}
+		}
+		step, err := p.parseStep()
+		if err != nil {
+			return nil, err
+		}
+		path.steps = append(path.steps, step)
+		sep = true
+	}
+}
+
+// isStepStart reports whether current token starts location step
+func (p *queryParser) isStepStart() bool {
+	tok := p.peek()
+	return tok.kind == queryName || tok.kind == querySymbol && (tok.text == "." || tok.text == ".." || tok.text == "@")
+}
+
+// isFunctionCall reports whether current token is function name, node type tests look like function calls too
+func (p *queryParser) isFunctionCall() bool {
+	tok, next := p.peek(), p.peekAt(1)
+	return tok.kind == queryName && next.kind == querySymbol && next.text == "(" && queryNodeTypes[tok.text] == 0
+}
+
+func (p *queryParser) parseFilter() (queryExpr, error) {
+	var (
+		primary queryExpr
+		err     error
+	)
+	tok := p.peek()
+	switch {
+	case tok.kind == queryLiteral:
+		p.i++
+		primary = queryStringExpr(tok.text)
+	case tok.kind == queryNumber:
+		p.i++
+		f, _ := strconv.ParseFloat(tok.text, 64)
+		primary = queryNumberExpr(f)
+	case tok.kind == querySymbol && tok.text == "(":
+		p.i++
+		if primary, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+		if err = p.expect(querySymbol, ")"); err != nil {
+			return nil, err
+		}
+	default:
+		if primary, err = p.parseFunction(); err != nil {
+			return nil, err
+		}
+	}
+
+	preds, err := p.parsePredicates()
+	if err != nil || len(preds) == 0 {
+		return primary, err
+	}
+	return &queryFilter{primary: primary, preds: preds}, nil
+}
+
+func (p *queryParser) parseFunction() (queryExpr, error) {
+	tok := p.peek()
+	fn, ok := queryFunctions[tok.text]
+	if !ok {
+		return nil, p.errorf(tok, "unknown function %s()", tok.text)
+	}
+	p.i += 2 //name (
+
+	call := &queryCall{name: tok.text, fn: fn}
+	if !p.accept(querySymbol, ")") {
+		for {
+			arg, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			call.args = append(call.args, arg)
+			if p.accept(querySymbol, ",") {
+				continue
+			}
+			if err := p.expect(querySymbol, ")"); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+	if len(call.args) < fn.min || fn.max != -1 && len(call.args) > fn.max {
+		return nil, p.errorf(tok, "wrong number of arguments for %s()", tok.text)
+	}
+	return call, nil
+}
+
+func (p *queryParser) parsePredicates() (preds []queryExpr, err error) {
+	for p.accept(querySymbol, "[") {
+		pred, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(querySymbol, "]"); err != nil {
+			return nil, err
+		}
+		preds = append(preds, pred)
+	}
+	return preds, nil
+}
+
+func (p *queryParser) parseStep() (*queryStep, error) {
+	step := &queryStep{axis: queryChildAxis}
+	tok := p.peek()
+	switch {
+	case p.accept(querySymbol, "."):
+		step.axis, step.test.kind = querySelfAxis, queryAnyNode
+		return step, nil
+	case p.accept(querySymbol, ".."):
+		step.axis, step.test.kind = queryParentAxis, queryAnyNode
+		return step, nil
+	case p.accept(querySymbol, "@"):
+		step.axis = queryAttributeAxis
+	case tok.kind == queryName && p.peekAt(1).kind == querySymbol && p.peekAt(1).text == "::":
+		axis, ok := queryAxes[tok.text]
+		if !ok {
+			return nil, p.errorf(tok, "unsupported axis %s", tok.text)
+		}
+		step.axis = axis
+		p.i += 2 //axis ::
+	}
+
+	tok = p.peek()
+	if tok.kind != queryName {
+		if tok.kind == queryEOF {
+			return nil, p.errorf(tok, "missing location step")
+		}
+		return nil, p.errorf(tok, "unexpected %q in location step", tok.text)
+	}
+	p.i++
+
+	if kind := queryNodeTypes[tok.text]; kind != 0 && p.accept(querySymbol, "(") {
+		step.test.kind = kind
+		if kind == queryProcInstNode && p.peek().kind == queryLiteral {
+			step.test.local = p.peek().text
+			p.i++
+		}
+		if err := p.expect(querySymbol, ")"); err != nil {
+			return nil, err
+		}
+	} else {
+		step.test.kind = queryNameTest
+		step.test.local = tok.text
+		if colon := strings.IndexByte(tok.text, ':'); colon != -1 {
+			step.test.prefix, step.test.local = tok.text[:colon], tok.text[colon+1:]
+		}
+	}
+
+	preds, err := p.parsePredicates()
+	if err != nil {
+		return nil, err
+	}
+	step.preds = preds
+	return step, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
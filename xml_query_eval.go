@@ -0,0 +1,580 @@
+package fastxml
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Query compiles expression and returns nodes it selects from document, see XPathExpr for supported subset
+func (xr *XMLReader) Query(expr string) ([]*Element, error) {
+	x, err := CompileXPath(expr)
+	if err != nil {
+		return nil, err
+	}
+	return xr.QueryXPath(x, nil), nil
+}
+
+/*
+QueryXPath returns nodes selected by compiled expression in document order, nil context is the document node,
+attribute nodes are not returned, their values can be selected by string() function
+*/
+func (xr *XMLReader) QueryXPath(x *XPathExpr, context *Element) []*Element {
+	result, _ := xr.EvaluateXPath(x, context).([]*Element)
+	return result
+}
+
+// EvaluateXPath returns result of compiled expression, one of []*Element, string, float64 or bool
+func (xr *XMLReader) EvaluateXPath(x *XPathExpr, context *Element) any {
+	if x == nil || len(xr.tree.nodes) == 0 {
+		return nil
+	}
+	e := &queryEval{xr: xr}
+	c := &queryContext{queryEval: e, node: queryNode{attr: -1}, pos: 1, size: 1}
+	if context != nil {
+		c.node.idx = context.idx
+	}
+
+	value := x.root.eval(c)
+	nodes, ok := value.(queryNodes)
+	if !ok {
+		return value
+	}
+	result := make([]*Element, 0, len(nodes))
+	for _, n := range nodes {
+		if n.attr == -1 {
+			result = append(result, &xr.tree.nodes[n.idx])
+		}
+	}
+	return result
+}
+
+// queryValue is result of expression, one of queryNodes, string, float64 or bool
+type queryValue = any
+
+type queryExpr interface {
+	eval(c *queryContext) queryValue
+}
+
+// queryNode is tree node or attribute of tree node when attr is not -1
+type queryNode struct {
+	idx, attr int
+}
+
+// queryNodes is node set, evaluated location steps keep it in document order
+type queryNodes []queryNode
+
+// queryEval holds per evaluation state of XPathExpr, compiled expression itself is never modified
+type queryEval struct {
+	xr    *XMLReader
+	attrs map[int][]Attribute //attributes of elements, parsed on first use
+}
+
+type queryContext struct {
+	*queryEval
+	node      queryNode
+	pos, size int //proximity position and context size
+}
+
+func (e *queryEval) token(n queryNode) *XMLToken {
+	return &e.xr.tree.nodes[n.idx].data
+}
+
+// attributes returns attributes of element, namespace declarations are not attributes in XPath data model
+func (e *queryEval) attributes(idx int) []Attribute {
+	if attrs, ok := e.attrs[idx]; ok {
+		return attrs
+	}
+	if e.attrs == nil {
+		e.attrs = make(map[int][]Attribute)
+	}
+
+	var attrs []Attribute
+	if token := &e.xr.tree.nodes[idx].data; idx != 0 && token.IsElement() {
+		in := e.xr.in
+		token.Name(in)
+		for _, attr := range token.ParseAttribute(in) {
+			if prefix := attr.Prefix(in); string(prefix) == "xmlns" || len(prefix) == 0 && string(attr.Key(in)) == "xmlns" {
+				continue
+			}
+			attrs = append(attrs, attr)
+		}
+	}
+	e.attrs[idx] = attrs
+	return attrs
+}
+
+// order returns position of node in document, attributes follow their element and precede its children
+func (e *queryEval) order(n queryNode) (int, int) {
+	if n.idx == 0 {
+		return -1, 0
+	}
+	return e.token(n).start.si, n.attr + 1
+}
+
+// sortNodes sorts nodes in document order and removes duplicates
+func (e *queryEval) sortNodes(nodes queryNodes) queryNodes {
+	sort.Slice(nodes, func(i, j int) bool {
+		si, ai := e.order(nodes[i])
+		sj, aj := e.order(nodes[j])
+		return si < sj || si == sj && ai < aj
+	})
+	result := nodes[:0]
+	for i, n := range nodes {
+		if i == 0 || n != nodes[i-1] {
+			result = append(result, n)
+		}
+	}
+	return result
+}
+
+// stringValue returns string-value of node as defined by XPath data model
+func (e *queryEval) stringValue(n queryNode) string {
+	xr := e.xr
+	if n.attr != -1 {
+		attrs := e.attributes(n.idx)
+		return xr.AttrValue(&attrs[n.attr])
+	}
+	token := e.token(n)
+	switch token.kind {
+	case textToken:
+		value, _ := xr.unescape(token.Text(xr.in), token.start.si, false)
+		return value
+	case cdataXMLToken, commentsXMLToken, processingXMLToken:
+		return string(token.Text(xr.in))
+	}
+	buf := strings.Builder{}
+	e.appendText(&buf, n.idx)
+	return buf.String()
+}
+
+// appendText writes text of all descendant text and CDATA of element, content is scanned as text nodes are recorded only with ParseOptions.CharData
+func (e *queryEval) appendText(buf *strings.Builder, idx int) {
+	nodes := e.xr.tree.nodes
+	if idx == 0 {
+		//document node, text outside of root element is ignored
+		for c := nodes[0].first; c != -1; c = nodes[c].next {
+			if nodes[c].data.IsElement() {
+				e.appendText(buf, c)
+			}
+		}
+		return
+	}
+	if token := &nodes[idx].data; !token.IsInline() {
+		u := unescaper{w: buf, entities: &e.xr.parser.entities}
+		writeMixedText(&u, e.xr.in, token.start.ei, token.end.si, true)
+	}
+}
+
+func (e *queryEval) toString(v queryValue) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return formatQueryNumber(v)
+	case bool:
+		if v {
+			return "true"
+		}
+		return "false"
+	case queryNodes:
+		if len(v) == 0 {
+			return ""
+		}
+		return e.stringValue(v[0])
+	}
+	return ""
+}
+
+func (e *queryEval) toNumber(v queryValue) float64 {
+	switch v := v.(type) {
+	case float64:
+		return v
+	case bool:
+		if v {
+			return 1
+		}
+		return 0
+	case string:
+		return parseQueryNumber(v)
+	case queryNodes:
+		return parseQueryNumber(e.toString(v))
+	}
+	return math.NaN()
+}
+
+func (e *queryEval) toBool(v queryValue) bool {
+	switch v := v.(type) {
+	case bool:
+		return v
+	case float64:
+		return v != 0 && !math.IsNaN(v)
+	case string:
+		return len(v) != 0
+	case queryNodes:
+		return len(v) != 0
+	}
+	return false
+}
+
+// compare compares values as per XPath 1.0, node sets match if any of their nodes matches
+func (e *queryEval) compare(op string, left, right queryValue) bool {
+	ln, lnodes := left.(queryNodes)
+	rn, rnodes := right.(queryNodes)
+	switch {
+	case lnodes && rnodes:
+		for _, l := range ln {
+			lv := e.stringValue(l)
+			for _, r := range rn {
+				if e.compareAtoms(op, lv, e.stringValue(r)) {
+					return true
+				}
+			}
+		}
+		return false
+	case lnodes:
+		if _, ok := right.(bool); ok {
+			return e.compareAtoms(op, len(ln) != 0, right)
+		}
+		for _, l := range ln {
+			if e.compareAtoms(op, e.stringValue(l), right) {
+				return true
+			}
+		}
+		return false
+	case rnodes:
+		return e.compare(reverseQueryOp(op), right, left)
+	}
+	return e.compareAtoms(op, left, right)
+}
+
+func (e *queryEval) compareAtoms(op string, left, right queryValue) bool {
+	if op == "=" || op == "!=" {
+		var equal bool
+		_, lbool := left.(bool)
+		_, rbool := right.(bool)
+		_, lnum := left.(float64)
+		_, rnum := right.(float64)
+		switch {
+		case lbool || rbool:
+			equal = e.toBool(left) == e.toBool(right)
+		case lnum || rnum:
+			equal = e.toNumber(left) == e.toNumber(right)
+		default:
+			equal = e.toString(left) == e.toString(right)
+		}
+		return equal == (op == "=")
+	}
+	l, r := e.toNumber(left), e.toNumber(right)
+	switch op {
+	case "<":
+		return l < r
+	case "<=":
+		return l <= r
+	case ">":
+		return l > r
+	case ">=":
+		return l >= r
+	}
+	return false
+}
+
+func reverseQueryOp(op string) string {
+	switch op {
+	case "<":
+		return ">"
+	case "<=":
+		return ">="
+	case ">":
+		return "<"
+	case ">=":
+		return "<="
+	}
+	return op
+}
+
+// parseQueryNumber converts string to number, anything but optional minus sign, digits and decimal point is NaN
+func parseQueryNumber(s string) float64 {
+	s = strings.Trim(s, " \t\r\n")
+	digits := strings.TrimPrefix(s, "-")
+	if digits == "" || digits == "." || strings.Trim(digits, "0123456789.") != "" || strings.Count(digits, ".") > 1 {
+		return math.NaN()
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return math.NaN()
+	}
+	return f
+}
+
+func formatQueryNumber(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	case f == 0:
+		return "0" //negative zero too
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+type queryStringExpr string
+
+func (l queryStringExpr) eval(*queryContext) queryValue {
+	return string(l)
+}
+
+type queryNumberExpr float64
+
+func (n queryNumberExpr) eval(*queryContext) queryValue {
+	return float64(n)
+}
+
+type queryNegate struct {
+	expr queryExpr
+}
+
+func (n *queryNegate) eval(c *queryContext) queryValue {
+	return -c.toNumber(n.expr.eval(c))
+}
+
+type queryBinary struct {
+	op          string
+	left, right queryExpr
+}
+
+func (b *queryBinary) eval(c *queryContext) queryValue {
+	switch b.op {
+	case "or":
+		return c.toBool(b.left.eval(c)) || c.toBool(b.right.eval(c))
+	case "and":
+		return c.toBool(b.left.eval(c)) && c.toBool(b.right.eval(c))
+	}
+
+	left, right := b.left.eval(c), b.right.eval(c)
+	switch b.op {
+	case "=", "!=", "<", "<=", ">", ">=":
+		return c.compare(b.op, left, right)
+	case "|":
+		ln, _ := left.(queryNodes)
+		rn, _ := right.(queryNodes)
+		return c.sortNodes(append(append(queryNodes{}, ln...), rn...))
+	}
+
+	l, r := c.toNumber(left), c.toNumber(right)
+	switch b.op {
+	case "+":
+		return l + r
+	case "-":
+		return l - r
+	case "*":
+		return l * r
+	case "div":
+		return l / r
+	case "mod":
+		return math.Mod(l, r)
+	}
+	return math.NaN()
+}
+
+type queryAxis int
+
+const (
+	queryChildAxis queryAxis = iota
+	queryDescendantAxis
+	queryDescendantOrSelfAxis
+	queryParentAxis
+	querySelfAxis
+	queryAttributeAxis
+)
+
+var queryAxes = map[string]queryAxis{
+	"child":              queryChildAxis,
+	"descendant":         queryDescendantAxis,
+	"descendant-or-self": queryDescendantOrSelfAxis,
+	"parent":             queryParentAxis,
+	"self":               querySelfAxis,
+	"attribute":          queryAttributeAxis,
+}
+
+type queryTestKind int
+
+const (
+	queryNameTest queryTestKind = iota
+	queryAnyNode
+	queryTextNode
+	queryCommentNode
+	queryProcInstNode
+)
+
+var queryNodeTypes = map[string]queryTestKind{
+	"node":                   queryAnyNode,
+	"text":                   queryTextNode,
+	"comment":                queryCommentNode,
+	"processing-instruction": queryProcInstNode,
+}
+
+// queryNodeTest is name test with optional prefix, local is * for any name, or node type test
+type queryNodeTest struct {
+	kind          queryTestKind
+	prefix, local string //local is target of processing-instruction('target') test
+}
+
+func (t *queryNodeTest) match(e *queryEval, n queryNode, axis queryAxis) bool {
+	in := e.xr.in
+	if n.attr != -1 {
+		//attributes are only matched by name test or node()
+		attr := e.attributes(n.idx)[n.attr]
+		return t.kind == queryAnyNode || t.kind == queryNameTest && t.matchName(attr.Prefix(in), attr.Key(in))
+	}
+	token := e.token(n)
+	switch t.kind {
+	case queryAnyNode:
+		return true
+	case queryTextNode:
+		return token.IsText()
+	case queryCommentNode:
+		return token.IsComment()
+	case queryProcInstNode:
+		return token.IsProcInst() && (t.local == "" || string(token.Name(in)) == t.local)
+	}
+	//principal node type of all supported axes but attribute is element
+	return axis != queryAttributeAxis && n.idx != 0 && token.IsElement() && t.matchName(token.Prefix(in), token.Name(in))
+}
+
+func (t *queryNodeTest) matchName(prefix, local []byte) bool {
+	if t.prefix != "" && string(prefix) != t.prefix {
+		return false
+	}
+	return t.local == "*" || string(local) == t.local
+}
+
+type queryStep struct {
+	axis  queryAxis
+	test  queryNodeTest
+	preds []queryExpr
+}
+
+// apply returns nodes selected by step from every input node, predicates are evaluated per input node
+func (s *queryStep) apply(e *queryEval, input queryNodes) queryNodes {
+	var result queryNodes
+	for _, n := range input {
+		nodes := s.axisNodes(e, n, nil)
+		for _, pred := range s.preds {
+			nodes = filterQueryNodes(e, nodes, pred)
+		}
+		result = append(result, nodes...)
+	}
+	if len(input) > 1 {
+		result = e.sortNodes(result)
+	}
+	return result
+}
+
+// axisNodes appends nodes of axis matching node test in document order
+func (s *queryStep) axisNodes(e *queryEval, n queryNode, result queryNodes) queryNodes {
+	nodes := e.xr.tree.nodes
+	add := func(c queryNode) {
+		if s.test.match(e, c, s.axis) {
+			result = append(result, c)
+		}
+	}
+	switch s.axis {
+	case queryChildAxis:
+		if n.attr == -1 {
+			for c := nodes[n.idx].first; c != -1; c = nodes[c].next {
+				add(queryNode{idx: c, attr: -1})
+			}
+		}
+	case queryDescendantOrSelfAxis:
+		add(n)
+		fallthrough
+	case queryDescendantAxis:
+		if n.attr == -1 {
+			var walk func(idx int)
+			walk = func(idx int) {
+				for c := nodes[idx].first; c != -1; c = nodes[c].next {
+					add(queryNode{idx: c, attr: -1})
+					walk(c)
+				}
+			}
+			walk(n.idx)
+		}
+	case queryParentAxis:
+		if n.attr != -1 {
+			add(queryNode{idx: n.idx, attr: -1})
+		} else if p := nodes[n.idx].parent; p != -1 {
+			add(queryNode{idx: p, attr: -1})
+		}
+	case querySelfAxis:
+		add(n)
+	case queryAttributeAxis:
+		if n.attr == -1 {
+			for i := range e.attributes(n.idx) {
+				add(queryNode{idx: n.idx, attr: i})
+			}
+		}
+	}
+	return result
+}
+
+// filterQueryNodes keeps nodes matching predicate, number predicate is compared with proximity position
+func filterQueryNodes(e *queryEval, nodes queryNodes, pred queryExpr) queryNodes {
+	var result queryNodes
+	for i, n := range nodes {
+		c := &queryContext{queryEval: e, node: n, pos: i + 1, size: len(nodes)}
+		value := pred.eval(c)
+		if f, ok := value.(float64); ok {
+			if f == float64(i+1) {
+				result = append(result, n)
+			}
+		} else if e.toBool(value) {
+			result = append(result, n)
+		}
+	}
+	return result
+}
+
+// queryPath is location path, relative to result of filter expression if present
+type queryPath struct {
+	filter   queryExpr
+	absolute bool
+	steps    []*queryStep
+}
+
+func (p *queryPath) eval(c *queryContext) queryValue {
+	var nodes queryNodes
+	switch {
+	case p.filter != nil:
+		nodes, _ = p.filter.eval(c).(queryNodes)
+	case p.absolute:
+		nodes = queryNodes{{idx: 0, attr: -1}}
+	default:
+		nodes = queryNodes{c.node}
+	}
+	for _, step := range p.steps {
+		if len(nodes) == 0 {
+			break
+		}
+		nodes = step.apply(c.queryEval, nodes)
+	}
+	return nodes
+}
+
+// queryFilter is primary expression followed by predicates
+type queryFilter struct {
+	primary queryExpr
+	preds   []queryExpr
+}
+
+func (f *queryFilter) eval(c *queryContext) queryValue {
+	nodes, _ := f.primary.eval(c).(queryNodes)
+	for _, pred := range f.preds {
+		nodes = filterQueryNodes(c.queryEval, nodes, pred)
+	}
+	return nodes
+}
@@ -0,0 +1,242 @@
+package fastxml
+
+import (
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+// queryFunction is XPath core function, max is -1 for variadic functions
+type queryFunction struct {
+	min, max int
+	call     func(c *queryContext, args []queryExpr) queryValue
+}
+
+type queryCall struct {
+	name string
+	fn   queryFunction
+	args []queryExpr
+}
+
+func (f *queryCall) eval(c *queryContext) queryValue {
+	return f.fn.call(c, f.args)
+}
+
+var queryFunctions = map[string]queryFunction{
+	//node set functions
+	"last":          {0, 0, func(c *queryContext, _ []queryExpr) queryValue { return float64(c.size) }},
+	"position":      {0, 0, func(c *queryContext, _ []queryExpr) queryValue { return float64(c.pos) }},
+	"count":         {1, 1, queryCount},
+	"name":          {0, 1, queryNodeName},
+	"local-name":    {0, 1, queryLocalName},
+	"namespace-uri": {0, 1, queryNamespaceURI},
+
+	//string functions
+	"string":           {0, 1, func(c *queryContext, args []queryExpr) queryValue { return c.stringArg(args, 0) }},
+	"concat":           {2, -1, queryConcat},
+	"starts-with":      {2, 2, queryStringsFunc(strings.HasPrefix)},
+	"contains":         {2, 2, queryStringsFunc(strings.Contains)},
+	"substring-before": {2, 2, querySubstringBefore},
+	"substring-after":  {2, 2, querySubstringAfter},
+	"substring":        {2, 3, querySubstring},
+	"string-length":    {0, 1, queryStringLength},
+	"normalize-space":  {0, 1, queryNormalizeSpace},
+	"translate":        {3, 3, queryTranslate},
+
+	//boolean functions
+	"boolean": {1, 1, func(c *queryContext, args []queryExpr) queryValue { return c.toBool(args[0].eval(c)) }},
+	"not":     {1, 1, func(c *queryContext, args []queryExpr) queryValue { return !c.toBool(args[0].eval(c)) }},
+	"true":    {0, 0, func(*queryContext, []queryExpr) queryValue { return true }},
+	"false":   {0, 0, func(*queryContext, []queryExpr) queryValue { return false }},
+
+	//number functions
+	"number":  {0, 1, queryNumberOf},
+	"sum":     {1, 1, querySum},
+	"floor":   {1, 1, queryMathFunc(math.Floor)},
+	"ceiling": {1, 1, queryMathFunc(math.Ceil)},
+	"round":   {1, 1, queryMathFunc(roundQueryNumber)},
+}
+
+// stringArg returns string value of argument i, context node if argument is omitted
+func (c *queryContext) stringArg(args []queryExpr, i int) string {
+	if i >= len(args) {
+		return c.stringValue(c.node)
+	}
+	return c.toString(args[i].eval(c))
+}
+
+// nodeArg returns first node of node set argument, context node if argument is omitted
+func (c *queryContext) nodeArg(args []queryExpr) (queryNode, bool) {
+	if len(args) == 0 {
+		return c.node, true
+	}
+	nodes, _ := args[0].eval(c).(queryNodes)
+	if len(nodes) == 0 {
+		return queryNode{}, false
+	}
+	return nodes[0], true
+}
+
+func queryCount(c *queryContext, args []queryExpr) queryValue {
+	nodes, _ := args[0].eval(c).(queryNodes)
+	return float64(len(nodes))
+}
+
+func queryNodeName(c *queryContext, args []queryExpr) queryValue {
+	n, ok := c.nodeArg(args)
+	if !ok {
+		return ""
+	}
+	in := c.xr.in
+	if n.attr != -1 {
+		attr := c.attributes(n.idx)[n.attr]
+		if prefix := attr.Prefix(in); len(prefix) != 0 {
+			return string(prefix) + ":" + string(attr.Key(in))
+		}
+		return string(attr.Key(in))
+	}
+	token := c.token(n)
+	switch {
+	case n.idx == 0:
+		return ""
+	case token.IsElement():
+		return string(token.NSName(in))
+	case token.IsProcInst():
+		return string(token.Name(in))
+	}
+	return ""
+}
+
+func queryLocalName(c *queryContext, args []queryExpr) queryValue {
+	n, ok := c.nodeArg(args)
+	if !ok {
+		return ""
+	}
+	in := c.xr.in
+	if n.attr != -1 {
+		return string(c.attributes(n.idx)[n.attr].Key(in))
+	}
+	if token := c.token(n); n.idx != 0 && (token.IsElement() || token.IsProcInst()) {
+		return string(token.Name(in))
+	}
+	return ""
+}
+
+func queryNamespaceURI(c *queryContext, args []queryExpr) queryValue {
+	n, ok := c.nodeArg(args)
+	if !ok || n.idx == 0 || !c.token(n).IsElement() {
+		return ""
+	}
+	node := &c.xr.tree.nodes[n.idx]
+	if n.attr != -1 {
+		attr := c.attributes(n.idx)[n.attr]
+		return c.xr.AttrNamespaceURI(node, &attr)
+	}
+	return c.xr.NamespaceURI(node)
+}
+
+func queryConcat(c *queryContext, args []queryExpr) queryValue {
+	buf := strings.Builder{}
+	for i := range args {
+		buf.WriteString(c.stringArg(args, i))
+	}
+	return buf.String()
+}
+
+func queryStringsFunc(f func(s, substr string) bool) func(*queryContext, []queryExpr) queryValue {
+	return func(c *queryContext, args []queryExpr) queryValue {
+		return f(c.stringArg(args, 0), c.stringArg(args, 1))
+	}
+}
+
+func querySubstringBefore(c *queryContext, args []queryExpr) queryValue {
+	s, sep := c.stringArg(args, 0), c.stringArg(args, 1)
+	if i := strings.Index(s, sep); i != -1 {
+		return s[:i]
+	}
+	return ""
+}
+
+func querySubstringAfter(c *queryContext, args []queryExpr) queryValue {
+	s, sep := c.stringArg(args, 0), c.stringArg(args, 1)
+	if i := strings.Index(s, sep); i != -1 {
+		return s[i+len(sep):]
+	}
+	return ""
+}
+
+// querySubstring returns characters at positions p where round(start) <= p < round(start)+round(length), positions are 1-based
+func querySubstring(c *queryContext, args []queryExpr) queryValue {
+	s := c.stringArg(args, 0)
+	first := roundQueryNumber(c.toNumber(args[1].eval(c)))
+	last := math.Inf(1)
+	if len(args) == 3 {
+		last = first + roundQueryNumber(c.toNumber(args[2].eval(c)))
+	}
+
+	buf := strings.Builder{}
+	pos := 1.0
+	for _, r := range s {
+		if pos >= first && pos < last {
+			buf.WriteRune(r)
+		}
+		pos++
+	}
+	return buf.String()
+}
+
+func queryStringLength(c *queryContext, args []queryExpr) queryValue {
+	return float64(utf8.RuneCountInString(c.stringArg(args, 0)))
+}
+
+func queryNormalizeSpace(c *queryContext, args []queryExpr) queryValue {
+	return strings.Join(strings.FieldsFunc(c.stringArg(args, 0), func(r rune) bool {
+		return r < utf8.RuneSelf && whitespace[r]
+	}), " ")
+}
+
+// queryTranslate replaces characters of from by characters of to at same position, characters without replacement are removed
+func queryTranslate(c *queryContext, args []queryExpr) queryValue {
+	s, from, to := c.stringArg(args, 0), []rune(c.stringArg(args, 1)), []rune(c.stringArg(args, 2))
+	return strings.Map(func(r rune) rune {
+		for i, f := range from {
+			if f == r {
+				if i < len(to) {
+					return to[i]
+				}
+				return -1
+			}
+		}
+		return r
+	}, s)
+}
+
+func queryNumberOf(c *queryContext, args []queryExpr) queryValue {
+	if len(args) == 0 {
+		return parseQueryNumber(c.stringValue(c.node))
+	}
+	return c.toNumber(args[0].eval(c))
+}
+
+func querySum(c *queryContext, args []queryExpr) queryValue {
+	nodes, _ := args[0].eval(c).(queryNodes)
+	sum := 0.0
+	for _, n := range nodes {
+		sum += parseQueryNumber(c.stringValue(n))
+	}
+	return sum
+}
+
+func queryMathFunc(f func(float64) float64) func(*queryContext, []queryExpr) queryValue {
+	return func(c *queryContext, args []queryExpr) queryValue {
+		return f(c.toNumber(args[0].eval(c)))
+	}
+}
+
+// roundQueryNumber rounds to closest integer, halves are rounded towards positive infinity
+func roundQueryNumber(f float64) float64 {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return f
+	}
+	return math.Floor(f + 0.5)
+}
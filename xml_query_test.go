@@ -0,0 +1,218 @@
+package fastxml
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var queryTestXML = []byte(`<?xml version="1.0"?>
+<VAST version="4.0" xmlns:v="http://vast">
+	<Ad id="1" sequence="2">
+		<Wrapper>
+			<Tracking event="start">http://w/start</Tracking>
+			<Tracking event="end">http://w/end</Tracking>
+		</Wrapper>
+	</Ad>
+	<Ad id="2">
+		<InLine>
+			<AdTitle>Title &amp; more</AdTitle>
+			<Creatives>
+				<Creative><Tracking event="start">http://i/start1</Tracking></Creative>
+				<Creative><Tracking event="start"><![CDATA[http://i/start2]]></Tracking><!-- c --></Creative>
+			</Creatives>
+			<v:Price>12.5</v:Price>
+		</InLine>
+	</Ad>
+	<Ad id="3"><Linear><Tracking event="start">http://l/start</Tracking></Linear></Ad>
+</VAST>`)
+
+func TestXMLReaderQuery(t *testing.T) {
+	reader := NewXMLReaderWithOptions(ParseOptions{CharData: true})
+	if !assert.NoError(t, reader.Parse(queryTestXML)) {
+		return
+	}
+
+	tests := []struct {
+		name string
+		expr string
+		want []string //text of selected nodes
+	}{
+		{name: "child_path", expr: "/VAST/Ad/Wrapper/Tracking", want: []string{"http://w/start", "http://w/end"}},
+		{name: "attribute_predicate", expr: "/VAST/Ad[@id='1']/*/Tracking[@event='end']", want: []string{"http://w/end"}},
+		{name: "self_or", expr: "/VAST/Ad[@id='2']/*[self::InLine or self::Wrapper]//Tracking[@event='start']", want: []string{"http://i/start1", "http://i/start2"}},
+		{name: "request_example", expr: "/VAST/Ad[@id='1']/*[self::InLine or self::Wrapper]//Tracking[@event='start']", want: []string{"http://w/start"}},
+		{name: "descendant", expr: "//Tracking[@event='start']", want: []string{"http://w/start", "http://i/start1", "http://i/start2", "http://l/start"}},
+		{name: "position", expr: "//Tracking[1]", want: []string{"http://w/start", "http://i/start1", "http://i/start2", "http://l/start"}},
+		{name: "position_of_set", expr: "(//Tracking)[2]", want: []string{"http://w/end"}},
+		{name: "last", expr: "/VAST/Ad[last()]/Linear/Tracking", want: []string{"http://l/start"}},
+		{name: "position_function", expr: "/VAST/Ad[position() > 2]//Tracking", want: []string{"http://l/start"}},
+		{name: "parent", expr: "//Tracking[@event='end']/../../@id/..//Tracking[1]", want: []string{"http://w/start"}},
+		{name: "descendant_axis", expr: "/VAST/descendant::AdTitle", want: []string{"Title & more"}},
+		{name: "prefix", expr: "//v:Price", want: []string{"12.5"}},
+		{name: "local_name", expr: "//Price[. > 10]", want: []string{"12.5"}},
+		{name: "starts_with", expr: "//Tracking[starts-with(., 'http://i/')]", want: []string{"http://i/start1", "http://i/start2"}},
+		{name: "cdata_is_text", expr: "//Tracking[contains(text(), 'start2')]", want: []string{"http://i/start2"}},
+		{name: "cdata_text", expr: "//Tracking[contains(., 'start2')]", want: []string{"http://i/start2"}},
+		{name: "text_nodes", expr: "//AdTitle/text()", want: []string{"Title & more"}},
+		{name: "comment", expr: "//comment()", want: []string{" c "}},
+		{name: "union", expr: "//AdTitle | //Wrapper/Tracking[1]", want: []string{"http://w/start", "Title & more"}},
+		{name: "not", expr: "/VAST/Ad[not(@sequence)]/@id/..//AdTitle", want: []string{"Title & more"}},
+		{name: "count", expr: "/VAST/Ad[count(.//Tracking) = 2]/Wrapper/Tracking[2]", want: []string{"http://w/end"}},
+		{name: "no_match", expr: "/VAST/Ad[@id='4']", want: nil},
+		{name: "attributes_not_returned", expr: "//Ad/@id", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodes, err := reader.Query(tt.expr)
+			if !assert.NoError(t, err) {
+				return
+			}
+			var got []string
+			for _, node := range nodes {
+				got = append(got, reader.Text(node))
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestXMLReaderEvaluateXPath(t *testing.T) {
+	reader := NewXMLReader()
+	if !assert.NoError(t, reader.Parse(queryTestXML)) {
+		return
+	}
+
+	tests := []struct {
+		expr string
+		want any
+	}{
+		{expr: "count(//Ad)", want: 3.0},
+		{expr: "string(//Ad[2]/@id)", want: "2"},
+		{expr: "string(/VAST/@version) = '4.0'", want: true},
+		{expr: "name(//Price)", want: "v:Price"},
+		{expr: "local-name(//v:Price)", want: "Price"},
+		{expr: "namespace-uri(//Price)", want: "http://vast"},
+		{expr: "count(/VAST/@*)", want: 1.0}, //xmlns declarations are not attributes
+		{expr: "concat('a', 1, true())", want: "a1true"},
+		{expr: "substring('12345', 1.5, 2.6)", want: "234"},
+		{expr: "substring('12345', 0, 3)", want: "12"},
+		{expr: "substring('12345', 2)", want: "2345"},
+		{expr: "substring-before('1999/04/01', '/')", want: "1999"},
+		{expr: "substring-after('1999/04/01', '/')", want: "04/01"},
+		{expr: "string-length('héllo')", want: 5.0},
+		{expr: "normalize-space('  a \n b  ')", want: "a b"},
+		{expr: "translate('--aaa--', 'abc-', 'ABC')", want: "AAA"},
+		{expr: "sum(//Price) * 2", want: 25.0},
+		{expr: "7 mod 3 + 7 div 2 - -1", want: 5.5},
+		{expr: "round(2.5) + floor(-1.5) + ceiling(1.2)", want: 3.0},
+		{expr: "1 < 2 and 2 <= 2 and 3 > 2 and 3 >= 4", want: false},
+		{expr: "//Ad/@id = 3", want: true},
+		{expr: "//Ad/@id != 3", want: true},
+		{expr: "string(1 div 0)", want: "Infinity"},
+		{expr: "string(2.50)", want: "2.5"},
+		{expr: "boolean(//Missing)", want: false},
+		{expr: "string(//AdTitle)", want: "Title & more"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			x, err := CompileXPath(tt.expr)
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tt.want, reader.EvaluateXPath(x, nil))
+		})
+	}
+
+	value := reader.EvaluateXPath(MustCompileXPath("number('abc')"), nil)
+	assert.True(t, math.IsNaN(value.(float64)))
+}
+
+func TestXMLReaderQueryWithoutCharData(t *testing.T) {
+	reader := NewXMLReader()
+	if !assert.NoError(t, reader.Parse(queryTestXML)) {
+		return
+	}
+	//string values are computed from content, text and comment nodes are recorded only with ParseOptions.CharData
+	nodes, err := reader.Query("//Tracking[contains(., 'start2')]")
+	if assert.NoError(t, err) && assert.Len(t, nodes, 1) {
+		assert.Equal(t, "http://i/start2", reader.Text(nodes[0]))
+	}
+	assert.Equal(t, "Title & more", reader.EvaluateXPath(MustCompileXPath("string(//AdTitle)"), nil))
+	assert.Equal(t, 0.0, reader.EvaluateXPath(MustCompileXPath("count(//text() | //comment())"), nil))
+}
+
+func TestXPathExprReuse(t *testing.T) {
+	x := MustCompileXPath("Tracking[@event='start']")
+	for _, doc := range []string{
+		`<Wrapper><Tracking event="start">a</Tracking></Wrapper>`,
+		`<Wrapper><Tracking event="end">b</Tracking><Tracking event="start">c</Tracking></Wrapper>`,
+	} {
+		reader := NewXMLReader()
+		if !assert.NoError(t, reader.Parse([]byte(doc))) {
+			return
+		}
+		wrapper := reader.SelectElement(nil, "Wrapper")
+		nodes := reader.QueryXPath(x, wrapper)
+		if assert.Len(t, nodes, 1) {
+			assert.Equal(t, "Tracking", reader.Name(nodes[0]))
+		}
+		//relative path from document node
+		assert.Empty(t, reader.QueryXPath(x, nil))
+	}
+}
+
+func TestCompileXPathErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		err  string
+	}{
+		{expr: "/VAST/Ad[@id='1'", err: `invalid xpath: missing "]" at offset 16 in "/VAST/Ad[@id='1'"`},
+		{expr: "/VAST/Ad[@id='1]", err: `invalid xpath: unterminated string literal at offset 13 in "/VAST/Ad[@id='1]"`},
+		{expr: "/VAST/following::Ad", err: `invalid xpath: unsupported axis following at offset 6 in "/VAST/following::Ad"`},
+		{expr: "foo(1)", err: `invalid xpath: unknown function foo() at offset 0 in "foo(1)"`},
+		{expr: "concat('a')", err: `invalid xpath: wrong number of arguments for concat() at offset 0 in "concat('a')"`},
+		{expr: "//Ad[$id]", err: `invalid xpath: variables are not supported at offset 5 in "//Ad[$id]"`},
+		{expr: "/VAST/", err: `invalid xpath: missing location step at offset 6 in "/VAST/"`},
+		{expr: "/VAST Ad", err: `invalid xpath: unexpected "Ad" at offset 6 in "/VAST Ad"`},
+		{expr: "1 ! 2", err: `invalid xpath: unexpected '!' at offset 2 in "1 ! 2"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := CompileXPath(tt.expr)
+			assert.ErrorIs(t, err, ErrInvalidXPath)
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+
+	reader := NewXMLReader()
+	_, err := reader.Query("//Ad[")
+	assert.ErrorIs(t, err, ErrInvalidXPath)
+}
+
+func Test_lexQuery(t *testing.T) {
+	tests := []struct {
+		expr string
+		want []string
+	}{
+		{expr: "*/a*b", want: []string{"name:*", "op:/", "name:a", "op:*", "name:b"}},
+		{expr: "div div div", want: []string{"name:div", "op:div", "name:div"}},
+		{expr: "@v:*[.5 >= 1]", want: []string{"sym:@", "name:v:*", "sym:[", "num:.5", "op:>=", "num:1", "sym:]"}},
+		{expr: "child::a//..", want: []string{"name:child", "sym:::", "name:a", "op://", "sym:.."}},
+	}
+	kinds := map[queryTokenKind]string{queryName: "name", queryNumber: "num", queryLiteral: "lit", queryOperator: "op", querySymbol: "sym"}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			tokens, err := lexQuery(tt.expr)
+			if !assert.NoError(t, err) {
+				return
+			}
+			var got []string
+			for _, tok := range tokens[:len(tokens)-1] {
+				got = append(got, kinds[tok.kind]+":"+tok.text)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -5,20 +5,29 @@ import (
 )
 
 type XMLReader struct {
-	in     []byte
-	tree   xmlTree
-	parser *XMLTokenizer
+	in       []byte
+	tree     xmlTree
+	parser   *XMLTokenizer
+	indexes  map[string]AttrIndex //attribute indexes of last parsed document, see BuildAttrIndex
+	encoding string               //encoding declared by document transcoded to UTF-8, see Declaration
 }
 
 func NewXMLReader() *XMLReader {
+	return NewXMLReaderWithOptions(ParseOptions{})
+}
+
+func NewXMLReaderWithOptions(opts ParseOptions) *XMLReader {
 	xr := &XMLReader{
-		parser: NewXMLTokenizer(),
+		parser: NewXMLTokenizerWithOptions(opts),
 	}
 	xr.tree = xmlTree{match: xr.match}
 	return xr
 }
 
 func (xr *XMLReader) match(name string, token XMLToken) bool {
+	if !token.IsElement() {
+		return false //character data nodes are not selected by name
+	}
 	return name == "*" || bytes.Equal(token.Name(xr.in), []byte(name))
 }
 
@@ -30,22 +39,108 @@ func (xr *XMLReader) RawXML() []byte {
 	return xr.in
 }
 
+/*
+Parse parses document, documents with BOM or declared encoding other than UTF-8 are transcoded
+to UTF-8 first, offsets of elements are relative to RawXML in that case
+*/
 func (xr *XMLReader) Parse(in []byte) error {
-	xr.tree.reset()
-	xr.in = in
-	return xr.parser.Parse(in, xr.tokenHandler)
+	return xr.parse(in, nil)
 }
 
 func (xr *XMLReader) ParseWithXPath(in []byte, ixpath *xpath) error {
+	return xr.parse(in, ixpath)
+}
+
+func (xr *XMLReader) parse(in []byte, ixpath *xpath) error {
 	xr.tree.reset()
-	xr.in = in
-	return xr.parser.ParseWithXPath(in, ixpath, xr.tokenHandler)
+	xr.indexes = nil
+	in, encoding, err := toUTF8(in)
+	if err != nil {
+		return err
+	}
+	xr.in, xr.encoding = in, encoding
+	if err := xr.parser.parse(in, ixpath, xr.tokenHandler); err != nil {
+		return err
+	}
+	if keys := xr.parser.opts.IndexAttrs; len(keys) != 0 {
+		xr.buildAttrIndexes(keys)
+	}
+	return nil
+}
+
+// Warnings returns recoveries done while parsing document in lenient mode
+func (xr *XMLReader) Warnings() []SyntaxError {
+	return xr.parser.Warnings()
+}
+
+/*
+Declaration returns xml declaration of document, nil if document doesn't start with one,
+fields and offsets refer to RawXML, OriginalEncoding is set if document was transcoded to UTF-8
+*/
+func (xr *XMLReader) Declaration() *Declaration {
+	decl := parseDeclaration(xr.in)
+	if decl != nil {
+		decl.OriginalEncoding = xr.encoding
+	}
+	return decl
 }
 
 func (xr *XMLReader) Childrens(parent *Element) (result []*Element) {
 	return xr.tree.getChilds(parent)
 }
 
+// Parent returns element enclosing node, nil for nodes at top level of document
+func (xr *XMLReader) Parent(node *Element) *Element {
+	if node == nil || node.parent <= 0 {
+		return nil
+	}
+	return &xr.tree.nodes[node.parent]
+}
+
+// PrevSibling returns element preceding node within its parent, text, comment and processing instruction nodes are skipped
+func (xr *XMLReader) PrevSibling(node *Element) *Element {
+	if node == nil || node.idx == 0 {
+		return nil
+	}
+	return xr.tree.getSibling(node, true)
+}
+
+// NextSibling returns element following node within its parent, text, comment and processing instruction nodes are skipped
+func (xr *XMLReader) NextSibling(node *Element) *Element {
+	if node == nil || node.idx == 0 {
+		return nil
+	}
+	return xr.tree.getSibling(node, false)
+}
+
+// Ancestors returns elements enclosing node starting from its parent up to root element
+func (xr *XMLReader) Ancestors(node *Element) (result []*Element) {
+	for node = xr.Parent(node); node != nil; node = xr.Parent(node) {
+		result = append(result, node)
+	}
+	return result
+}
+
+// Depth returns number of elements enclosing node, 0 for root element
+func (xr *XMLReader) Depth(node *Element) (depth int) {
+	for node = xr.Parent(node); node != nil; node = xr.Parent(node) {
+		depth++
+	}
+	return depth
+}
+
+// ChildIndex returns 0-based position of element among child elements of its parent as returned by Childrens, -1 for other nodes
+func (xr *XMLReader) ChildIndex(node *Element) int {
+	if node == nil || node.idx == 0 || !node.data.IsElement() {
+		return -1
+	}
+	index := 0
+	for node = xr.tree.getSibling(node, true); node != nil; node = xr.tree.getSibling(node, true) {
+		index++
+	}
+	return index
+}
+
 func (xr *XMLReader) SelectElement(parent *Element, path ...string) *Element {
 	if len(path) == 1 {
 		return xr.tree.getChild(parent, path[0])
@@ -77,16 +172,187 @@ func (xr *XMLReader) SelectAttrValue(node *Element, key string, defaultValue str
 	return defaultValue
 }
 
+// SelectElementNS returns first child of parent with local name in namespace uri
+func (xr *XMLReader) SelectElementNS(parent *Element, uri, local string) *Element {
+	for _, child := range xr.tree.getAllChild(parent, local) {
+		if xr.NamespaceURI(child) == uri {
+			return child
+		}
+	}
+	return nil
+}
+
+// SelectElementsNS returns all childs of parent with local name in namespace uri
+func (xr *XMLReader) SelectElementsNS(parent *Element, uri, local string) (result []*Element) {
+	for _, child := range xr.tree.getAllChild(parent, local) {
+		if xr.NamespaceURI(child) == uri {
+			result = append(result, child)
+		}
+	}
+	return result
+}
+
+// DescendantQuery selects descendant elements by FindDescendantsBy, zero value selects all elements
+type DescendantQuery struct {
+	Name     string              //local name, empty or * for any name
+	URI      string              //namespace uri, empty for any namespace
+	Match    func(*Element) bool //optional predicate, called only for elements matching Name and URI
+	MaxDepth int                 //levels below parent to search, 1 for childs only, 0 for unlimited
+}
+
+// FindDescendants returns all elements below parent with local name in document order, nil parent searches whole document
+func (xr *XMLReader) FindDescendants(parent *Element, name string) []*Element {
+	return xr.FindDescendantsBy(parent, DescendantQuery{Name: name})
+}
+
+// FindFirstDescendant returns first element below parent with local name in document order
+func (xr *XMLReader) FindFirstDescendant(parent *Element, name string) *Element {
+	return xr.FindFirstDescendantBy(parent, DescendantQuery{Name: name})
+}
+
+// FindDescendantsBy returns all elements below parent selected by query in document order
+func (xr *XMLReader) FindDescendantsBy(parent *Element, query DescendantQuery) []*Element {
+	return xr.tree.find(parent, query.MaxDepth, xr.descendantMatcher(query), false)
+}
+
+// FindFirstDescendantBy returns first element below parent selected by query in document order
+func (xr *XMLReader) FindFirstDescendantBy(parent *Element, query DescendantQuery) *Element {
+	if result := xr.tree.find(parent, query.MaxDepth, xr.descendantMatcher(query), true); len(result) != 0 {
+		return result[0]
+	}
+	return nil
+}
+
+func (xr *XMLReader) descendantMatcher(query DescendantQuery) func(*Element) bool {
+	return func(node *Element) bool {
+		if query.Name != "" && !xr.match(query.Name, node.data) {
+			return false
+		}
+		if query.URI != "" && xr.NamespaceURI(node) != query.URI {
+			return false
+		}
+		return query.Match == nil || query.Match(node)
+	}
+}
+
+// SelectAttrNS returns attribute of node with local name in namespace uri
+func (xr *XMLReader) SelectAttrNS(node *Element, uri, local string) *Attribute {
+	attr := node.data.ParseAttribute(xr.in)
+	for i := range attr {
+		if bytes.Equal(attr[i].Key(xr.in), []byte(local)) && xr.AttrNamespaceURI(node, &attr[i]) == uri {
+			return &attr[i]
+		}
+	}
+	return nil
+}
+
+// NamespaceURI returns namespace uri of element, empty if element is not in any namespace
+func (xr *XMLReader) NamespaceURI(node *Element) string {
+	uri, _ := xr.parser.lookupNamespace(xr.in, node.data.scope, node.data.Prefix(xr.in))
+	return string(uri)
+}
+
+// AttrNamespaceURI returns namespace uri of attribute of node, unprefixed attributes are not in any namespace
+func (xr *XMLReader) AttrNamespaceURI(node *Element, attr *Attribute) string {
+	prefix := attr.Prefix(xr.in)
+	if len(prefix) == 0 {
+		return ""
+	}
+	uri, _ := xr.parser.lookupNamespace(xr.in, node.data.scope, prefix)
+	return string(uri)
+}
+
 func (xr *XMLReader) XMLTag(node *Element) []byte {
 	return node.data.XMLTag(xr.in)
 }
 
+/*
+Text returns direct character data of node with references decoded, text of child elements is not included,
+text and CDATA segments of mixed content are concatenated, use InnerXML for raw markup
+*/
 func (xr *XMLReader) Text(node *Element) (value string) {
-	if !node.data.IsCDATA(xr.in) {
-		//unescape and return
-		return string(unescapeBytes(node.data.Text(xr.in)))
+	value, _ = xr.text(node, false)
+	return value
+}
+
+// TextStrict returns text like Text, but fails on invalid character or entity references
+func (xr *XMLReader) TextStrict(node *Element) (value string, err error) {
+	return xr.text(node, true)
+}
+
+func (xr *XMLReader) text(node *Element, strict bool) (string, error) {
+	if node.data.IsComment() || node.data.IsProcInst() {
+		return string(node.data.Text(xr.in)), nil
+	}
+	if node.data.IsElement() && !node.data.IsInline() {
+		if content := xr.in[node.data.start.ei:node.data.end.si]; bytes.IndexByte(content, '<') != -1 {
+			return xr.mixedText(node, strict)
+		}
+	}
+
+	text := node.data.Text(xr.in)
+	if node.data.IsCDATA(xr.in) {
+		return string(trimSpaceBytes(text)), nil
+	}
+	offset := node.data.start.si
+	if node.data.IsElement() {
+		offset = node.data.text.si
+	}
+	return xr.unescape(text, offset, strict)
+}
+
+// mixedText concatenates text and CDATA content of element, result is trimmed if any CDATA is present
+func (xr *XMLReader) mixedText(node *Element, strict bool) (string, error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	u := unescaper{w: buf, strict: strict, entities: &xr.parser.entities}
+	cdata, err := writeMixedText(&u, xr.in, node.data.start.ei, node.data.end.si, false)
+	if err != nil {
+		uerr := err.(*unescapeError)
+		return "", newSyntaxError(xr.in, uerr.index, textToken, uerr.reason)
+	}
+	if cdata {
+		return string(trimSpaceBytes(buf.Bytes())), nil
 	}
-	return string(trimSpaceBytes(node.data.Text(xr.in)))
+	return buf.String(), nil
+}
+
+// InnerXML returns raw markup between start and end tag of element, raw text of text nodes
+func (xr *XMLReader) InnerXML(node *Element) string {
+	if !node.data.IsElement() {
+		return string(node.data.Text(xr.in))
+	}
+	if node.data.IsInline() {
+		return ""
+	}
+	return string(xr.in[node.data.start.ei:node.data.end.si])
+}
+
+// AttrValue returns attribute value with entities and character references decoded
+func (xr *XMLReader) AttrValue(attr *Attribute) string {
+	value, _ := xr.unescape(attr.Value(xr.in), attr.value.si, false)
+	return value
+}
+
+// AttrValueStrict returns attribute value like AttrValue, but fails on invalid character or entity references
+func (xr *XMLReader) AttrValueStrict(attr *Attribute) (string, error) {
+	return xr.unescape(attr.Value(xr.in), attr.value.si, true)
+}
+
+// unescape decodes references using entities declared in DOCTYPE, offset is position of in used for error reporting
+func (xr *XMLReader) unescape(in []byte, offset int, strict bool) (string, error) {
+	if bytes.IndexByte(in, '&') == -1 {
+		return string(in), nil
+	}
+	buf := getBuffer()
+	defer putBuffer(buf)
+	u := unescaper{w: buf, strict: strict, entities: &xr.parser.entities}
+	if err := u.unescape(in, 0); err != nil {
+		uerr := err.(*unescapeError)
+		return "", newSyntaxError(xr.in, offset+uerr.index, textToken, uerr.reason)
+	}
+	return buf.String(), nil
 }
 
 func (xr *XMLReader) RawText(node *Element) (value string) {
@@ -109,6 +375,26 @@ func (xr *XMLReader) IsCDATA(node *Element) bool {
 	return node.data.IsCDATA(xr.in)
 }
 
+// IsComment reports whether node is a comment, Text returns its content
+func (xr *XMLReader) IsComment(node *Element) bool {
+	return node.data.IsComment()
+}
+
+// IsProcInst reports whether node is a processing instruction, Name returns its target and Text its data
+func (xr *XMLReader) IsProcInst(node *Element) bool {
+	return node.data.IsProcInst()
+}
+
+// Comments returns comments within parent in document order, nil parent returns all comments of document, requires ParseOptions.CharData
+func (xr *XMLReader) Comments(parent *Element) []*Element {
+	return xr.tree.getNodes(parent, commentsXMLToken)
+}
+
+// ProcInsts returns processing instructions within parent in document order, xml declaration is not included, requires ParseOptions.CharData
+func (xr *XMLReader) ProcInsts(parent *Element) []*Element {
+	return xr.tree.getNodes(parent, processingXMLToken)
+}
+
 func (xr *XMLReader) Iterate(cb func(*Element)) {
 	xr.tree.iterate(cb)
 }
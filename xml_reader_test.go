@@ -103,3 +103,237 @@ func TestXMLReader2(t *testing.T) {
 		t.Logf("\n/Catalog/Book/Genre[%d] = %v", i, xmlReader.Text(element))
 	}
 }
+
+func TestXMLReaderCharRefs(t *testing.T) {
+	in := []byte(`<Ad title="Caf&#233; &#x20AC;5" bad="&#xD800;"><Price>&#8364;10 &amp; caf&#xE9;</Price><Bad>x&#0;</Bad></Ad>`)
+	reader := NewXMLReader()
+	if !assert.NoError(t, reader.Parse(in)) {
+		return
+	}
+
+	price := reader.SelectElement(nil, "Ad", "Price")
+	assert.Equal(t, "€10 & café", reader.Text(price))
+	text, err := reader.TextStrict(price)
+	assert.NoError(t, err)
+	assert.Equal(t, "€10 & café", text)
+
+	bad := reader.SelectElement(nil, "Ad", "Bad")
+	assert.Equal(t, "x&#0;", reader.Text(bad))
+	_, err = reader.TextStrict(bad)
+	assert.ErrorIs(t, err, ErrInvalidXML)
+	assert.EqualError(t, err, "invalid xml: invalid character reference &#0; at line 1, column 94 (offset 93)")
+
+	ad := reader.SelectElement(nil, "Ad")
+	assert.Equal(t, "Café €5", reader.AttrValue(reader.SelectAttr(ad, "title")))
+	assert.Equal(t, "&#xD800;", reader.AttrValue(reader.SelectAttr(ad, "bad")))
+	_, err = reader.AttrValueStrict(reader.SelectAttr(ad, "bad"))
+	assert.ErrorIs(t, err, ErrInvalidXML)
+}
+
+func TestXMLReaderMixedContent(t *testing.T) {
+	in := []byte(`<a>x &amp; <b>y</b>z<c/><d><![CDATA[ p ]]> q <![CDATA[r ]]></d><e><![CDATA[ s ]]></e><f/><g><!--c--><?p i?></g></a>`)
+	reader := NewXMLReader()
+	if !assert.NoError(t, reader.Parse(in)) {
+		return
+	}
+
+	a := reader.SelectElement(nil, "a")
+	assert.Equal(t, "x & z", reader.Text(a))
+	assert.Equal(t, `x &amp; <b>y</b>z<c/><d><![CDATA[ p ]]> q <![CDATA[r ]]></d><e><![CDATA[ s ]]></e><f/><g><!--c--><?p i?></g>`, reader.InnerXML(a))
+	assert.False(t, a.IsLeaf())
+
+	//text nodes are not returned as child elements
+	names := []string{}
+	for _, child := range reader.Childrens(a) {
+		names = append(names, reader.Name(child))
+	}
+	assert.Equal(t, []string{"b", "c", "d", "e", "f", "g"}, names)
+
+	assert.Equal(t, "b", reader.Name(reader.SelectElement(a, "*")))
+	assert.Len(t, reader.SelectElements(nil, "a", "*"), 6)
+
+	d := reader.SelectElement(a, "d")
+	assert.True(t, d.IsLeaf())
+	assert.Equal(t, "p  q r", reader.Text(d))
+	assert.Equal(t, `<![CDATA[ p ]]> q <![CDATA[r ]]>`, reader.InnerXML(d))
+
+	assert.Equal(t, "s", reader.Text(reader.SelectElement(a, "e")))
+	assert.Equal(t, "y", reader.Text(reader.SelectElement(a, "b")))
+	assert.Equal(t, "", reader.Text(reader.SelectElement(a, "f")))
+	assert.Equal(t, "", reader.InnerXML(reader.SelectElement(a, "f")))
+	assert.Equal(t, "", reader.Text(reader.SelectElement(a, "g")))
+
+	_, err := reader.TextStrict(a)
+	assert.NoError(t, err)
+}
+
+func TestXMLReaderMixedContentStrict(t *testing.T) {
+	in := []byte(`<a>x<b/>&#0;</a>`)
+	reader := NewXMLReader()
+	if !assert.NoError(t, reader.Parse(in)) {
+		return
+	}
+	_, err := reader.TextStrict(reader.SelectElement(nil, "a"))
+	assert.EqualError(t, err, "invalid xml: invalid character reference &#0; at line 1, column 9 (offset 8)")
+}
+
+func TestXMLReaderCommentsAndProcInsts(t *testing.T) {
+	in := []byte(`<?xml version="1.0"?><!-- served by ad-01 --><?xml-stylesheet href="vast.xsl"?><VAST><Ad id="1"><!-- adid=123 --><Wrapper><?trace  id=7 ?><!--x--></Wrapper>text</Ad></VAST><!-- end -->`)
+	reader := NewXMLReaderWithOptions(ParseOptions{CharData: true})
+	if !assert.NoError(t, reader.Parse(in)) {
+		return
+	}
+
+	comments := []string{}
+	for _, node := range reader.Comments(nil) {
+		assert.True(t, reader.IsComment(node))
+		comments = append(comments, reader.Text(node))
+	}
+	assert.Equal(t, []string{" served by ad-01 ", " adid=123 ", "x", " end "}, comments)
+
+	ad := reader.SelectElement(nil, "VAST", "Ad")
+	assert.Len(t, reader.Comments(ad), 2)
+	assert.Equal(t, "<!-- adid=123 -->", string(reader.XMLTag(reader.Comments(ad)[0])))
+
+	//xml declaration is not a processing instruction node
+	pis := reader.ProcInsts(nil)
+	if assert.Len(t, pis, 2) {
+		assert.True(t, reader.IsProcInst(pis[0]))
+		assert.Equal(t, "xml-stylesheet", reader.Name(pis[0]))
+		assert.Equal(t, `href="vast.xsl"`, reader.Text(pis[0]))
+		assert.Equal(t, "trace", reader.Name(pis[1]))
+		assert.Equal(t, "id=7 ", reader.Text(pis[1]))
+	}
+
+	//comments are not part of text or child elements
+	assert.Equal(t, "text", reader.Text(ad))
+	assert.Equal(t, "", reader.Text(reader.SelectElement(ad, "Wrapper")))
+	assert.Len(t, reader.Childrens(ad), 1)
+	assert.Equal(t, in, []byte(reader.getXML(in)))
+
+	//nodes are recorded only on request
+	reader = NewXMLReader()
+	if assert.NoError(t, reader.Parse(in)) {
+		assert.Empty(t, reader.Comments(nil))
+		assert.Empty(t, reader.ProcInsts(nil))
+		assert.Equal(t, "text", reader.Text(reader.SelectElement(nil, "VAST", "Ad")))
+	}
+}
+
+func TestXMLReaderCommentsAndProcInstsUnterminated(t *testing.T) {
+	for _, in := range []string{`<a><!--></a>`, `<a><!---></a>`, `<a><?></a>`} {
+		t.Run(in, func(t *testing.T) {
+			reader := NewXMLReaderWithOptions(ParseOptions{CharData: true})
+			assert.ErrorIs(t, reader.Parse([]byte(in)), ErrInvalidXML)
+		})
+	}
+}
+
+func TestXMLReaderNavigation(t *testing.T) {
+	in := []byte(`<VAST><Ad><Creatives>
+	<Creative id="1"><Linear><MediaFiles><MediaFile>a.mp4</MediaFile></MediaFiles></Linear></Creative>
+	<!-- companion -->
+	<Creative id="2"/>
+	text
+	<Creative id="3"><Linear/></Creative>
+</Creatives></Ad></VAST>`)
+	reader := NewXMLReaderWithOptions(ParseOptions{CharData: true})
+	if !assert.NoError(t, reader.Parse(in)) {
+		return
+	}
+
+	vast := reader.SelectElement(nil, "VAST")
+	assert.Nil(t, reader.Parent(vast))
+	assert.Nil(t, reader.Parent(nil))
+	assert.Empty(t, reader.Ancestors(vast))
+	assert.Equal(t, 0, reader.Depth(vast))
+	assert.Equal(t, 0, reader.ChildIndex(vast))
+
+	mediaFile := reader.SelectElement(nil, "VAST", "Ad", "Creatives", "Creative", "Linear", "MediaFiles", "MediaFile")
+	creative := reader.Parent(reader.Parent(reader.Parent(mediaFile)))
+	assert.Equal(t, "1", reader.SelectAttrValue(creative, "id", ""))
+	assert.Equal(t, 6, reader.Depth(mediaFile))
+
+	names := []string{}
+	for _, ancestor := range reader.Ancestors(mediaFile) {
+		names = append(names, reader.Name(ancestor))
+	}
+	assert.Equal(t, []string{"MediaFiles", "Linear", "Creative", "Creatives", "Ad", "VAST"}, names)
+
+	//text and comment nodes are skipped
+	second := reader.NextSibling(creative)
+	assert.Equal(t, "2", reader.SelectAttrValue(second, "id", ""))
+	assert.Equal(t, 1, reader.ChildIndex(second))
+	third := reader.NextSibling(second)
+	assert.Equal(t, "3", reader.SelectAttrValue(third, "id", ""))
+	assert.Equal(t, 2, reader.ChildIndex(third))
+	assert.Nil(t, reader.NextSibling(third))
+	assert.Equal(t, second, reader.PrevSibling(third))
+	assert.Equal(t, creative, reader.PrevSibling(second))
+	assert.Nil(t, reader.PrevSibling(creative))
+	assert.Equal(t, reader.Childrens(reader.Parent(creative))[2], third)
+
+	comment := reader.Comments(nil)[0]
+	assert.Equal(t, reader.Parent(creative), reader.Parent(comment))
+	assert.Equal(t, -1, reader.ChildIndex(comment))
+}
+
+func TestXMLReaderNavigationXPath(t *testing.T) {
+	in := []byte(`<VAST><Ad><InLine><Creatives><Creative><MediaFile>a</MediaFile><MediaFile>b</MediaFile></Creative></Creatives></InLine></Ad></VAST>`)
+	reader := NewXMLReader()
+	if !assert.NoError(t, reader.ParseWithXPath(in, GetXPath([][]string{{"VAST", "Ad", "//", "MediaFile"}}))) {
+		return
+	}
+
+	//parent is nearest reported ancestor
+	files := reader.SelectElements(nil, "VAST", "Ad", "MediaFile")
+	if assert.Len(t, files, 2) {
+		assert.Equal(t, "Ad", reader.Name(reader.Parent(files[1])))
+		assert.Equal(t, 2, reader.Depth(files[1]))
+		assert.Equal(t, files[0], reader.PrevSibling(files[1]))
+	}
+}
+
+func TestXMLReaderFindDescendants(t *testing.T) {
+	in := []byte(`<VAST xmlns:x="http://x"><Ad id="1"><InLine><Creatives>
+	<Creative><Linear><TrackingEvents><Tracking event="start">l1</Tracking><Tracking event="end">l2</Tracking></TrackingEvents></Linear></Creative>
+	<Creative><NonLinearAds><TrackingEvents><x:Tracking event="start">n1</x:Tracking></TrackingEvents></NonLinearAds></Creative>
+	<Creative><CompanionAds><Companion><TrackingEvents><Tracking event="creativeView">c1</Tracking></TrackingEvents></Companion></CompanionAds></Creative>
+</Creatives></InLine></Ad><Ad id="2"><Wrapper><Tracking event="start">w1</Tracking></Wrapper></Ad></VAST>`)
+	reader := NewXMLReader()
+	if !assert.NoError(t, reader.Parse(in)) {
+		return
+	}
+	texts := func(nodes []*Element) (result []string) {
+		for _, node := range nodes {
+			result = append(result, reader.Text(node))
+		}
+		return result
+	}
+
+	ad := reader.FindFirstDescendant(nil, "Ad")
+	assert.Equal(t, "1", reader.SelectAttrValue(ad, "id", ""))
+	assert.Equal(t, []string{"l1", "l2", "n1", "c1"}, texts(reader.FindDescendants(ad, "Tracking")))
+	assert.Equal(t, []string{"l1", "l2", "n1", "c1", "w1"}, texts(reader.FindDescendants(nil, "Tracking")))
+	assert.Equal(t, "l1", reader.Text(reader.FindFirstDescendant(ad, "Tracking")))
+	assert.Nil(t, reader.FindFirstDescendant(ad, "Missing"))
+	assert.Empty(t, reader.FindDescendants(ad, "Missing"))
+
+	//namespace
+	assert.Equal(t, []string{"n1"}, texts(reader.FindDescendantsBy(ad, DescendantQuery{Name: "Tracking", URI: "http://x"})))
+
+	//predicate
+	start := func(node *Element) bool { return reader.SelectAttrValue(node, "event", "") == "start" }
+	assert.Equal(t, []string{"l1", "n1", "w1"}, texts(reader.FindDescendantsBy(nil, DescendantQuery{Name: "Tracking", Match: start})))
+	assert.Equal(t, "w1", reader.Text(reader.FindFirstDescendantBy(nil, DescendantQuery{Match: func(node *Element) bool {
+		return reader.Name(node) == "Tracking" && reader.Name(reader.Parent(node)) == "Wrapper"
+	}})))
+
+	//depth limits
+	assert.Len(t, reader.FindDescendantsBy(ad, DescendantQuery{MaxDepth: 1}), 1)
+	assert.Len(t, reader.FindDescendantsBy(ad, DescendantQuery{Name: "Creative", MaxDepth: 2}), 0)
+	assert.Len(t, reader.FindDescendantsBy(ad, DescendantQuery{Name: "Creative", MaxDepth: 3}), 3)
+	assert.Equal(t, []string{"l1", "l2", "n1"}, texts(reader.FindDescendantsBy(ad, DescendantQuery{Name: "*", MaxDepth: 6, Match: func(node *Element) bool {
+		return reader.Name(node) == "Tracking"
+	}})))
+}
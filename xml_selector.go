@@ -0,0 +1,468 @@
+package fastxml
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrInvalidSelector is the sentinel matched by every *SelectorError, use errors.Is(err, ErrInvalidSelector)
+var ErrInvalidSelector = errors.New("invalid selector")
+
+// SelectorError is returned by CompileSelector for selectors outside of supported subset
+type SelectorError struct {
+	Selector string
+	Offset   int //byte offset of the offending character in Selector
+	Reason   string
+}
+
+func (e *SelectorError) Error() string {
+	return fmt.Sprintf("%s: %s at offset %d in %q", ErrInvalidSelector.Error(), e.Reason, e.Offset, e.Selector)
+}
+
+func (e *SelectorError) Unwrap() error {
+	return ErrInvalidSelector
+}
+
+/*
+Selector is compiled CSS selector, it doesn't refer any document and can be shared
+between readers and goroutines, supported subset is
+
+	selectors:   name, *, #id, [attr], [attr=v], [attr^=v], [attr$=v], [attr*=v] and comma separated lists
+	combinators: descendant (whitespace) and child (>)
+	pseudos:     :first-child, :last-child, :nth-child(an+b), :first-of-type, :last-of-type, :nth-of-type(an+b)
+
+name matches local name of element regardless of its namespace prefix, values may be quoted
+*/
+type Selector struct {
+	sel    string
+	chains [][]selectorCompound
+}
+
+// selectorCompound is sequence of simple selectors, combinator relates it to previous compound of chain
+type selectorCompound struct {
+	combinator byte   //' ' descendant, '>' child, 0 for first compound
+	name       string //empty or * for any element
+	attrs      []selectorAttr
+	pseudos    []selectorPseudo
+}
+
+type selectorAttr struct {
+	key, op, value string //empty op checks only presence of attribute
+}
+
+// selectorPseudo matches 1-based position p of element among siblings if p = a*n + b for some n >= 0
+type selectorPseudo struct {
+	a, b   int
+	ofType bool //only siblings with same name are counted
+	last   bool //positions are counted from last sibling
+}
+
+// CompileSelector compiles selector to be evaluated by XMLReader.SelectWith
+func CompileSelector(sel string) (*Selector, error) {
+	p := &selectorParser{sel: sel}
+	s := &Selector{sel: sel}
+	for {
+		chain, err := p.parseChain()
+		if err != nil {
+			return nil, err
+		}
+		s.chains = append(s.chains, chain)
+		if p.eof() {
+			return s, nil
+		}
+		p.i++ //,
+	}
+}
+
+// MustCompileSelector is like CompileSelector but panics on invalid selector
+func MustCompileSelector(sel string) *Selector {
+	s, err := CompileSelector(sel)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// String returns source of selector
+func (s *Selector) String() string {
+	return s.sel
+}
+
+// Select compiles selector and returns elements of document it matches, see Selector for supported subset
+func (xr *XMLReader) Select(sel string) ([]*Element, error) {
+	s, err := CompileSelector(sel)
+	if err != nil {
+		return nil, err
+	}
+	return xr.SelectWith(s, nil), nil
+}
+
+// SelectWith returns elements below parent matched by compiled selector in document order, nil parent searches whole document
+func (xr *XMLReader) SelectWith(s *Selector, parent *Element) []*Element {
+	if s == nil {
+		return nil
+	}
+	return xr.tree.find(parent, 0, func(node *Element) bool {
+		for _, chain := range s.chains {
+			if xr.matchChain(chain, len(chain)-1, node) {
+				return true
+			}
+		}
+		return false
+	}, false)
+}
+
+// matchChain matches compound i of chain against node and rest of chain against its ancestors, right to left
+func (xr *XMLReader) matchChain(chain []selectorCompound, i int, node *Element) bool {
+	if !xr.matchCompound(&chain[i], node) {
+		return false
+	}
+	if i == 0 {
+		return true
+	}
+	switch chain[i].combinator {
+	case '>':
+		parent := xr.Parent(node)
+		return parent != nil && xr.matchChain(chain, i-1, parent)
+	default:
+		for parent := xr.Parent(node); parent != nil; parent = xr.Parent(parent) {
+			if xr.matchChain(chain, i-1, parent) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (xr *XMLReader) matchCompound(c *selectorCompound, node *Element) bool {
+	if c.name != "" && !xr.match(c.name, node.data) {
+		return false
+	}
+	for _, attr := range c.attrs {
+		a := xr.SelectAttr(node, attr.key)
+		if a == nil {
+			return false
+		}
+		if attr.op == "" {
+			continue
+		}
+		value := xr.AttrValue(a)
+		switch attr.op {
+		case "=":
+			if value != attr.value {
+				return false
+			}
+		case "^=":
+			if attr.value == "" || !strings.HasPrefix(value, attr.value) {
+				return false
+			}
+		case "$=":
+			if attr.value == "" || !strings.HasSuffix(value, attr.value) {
+				return false
+			}
+		case "*=":
+			if attr.value == "" || !strings.Contains(value, attr.value) {
+				return false
+			}
+		}
+	}
+	for _, pseudo := range c.pseudos {
+		if !pseudo.match(xr.siblingPosition(node, pseudo.ofType, pseudo.last)) {
+			return false
+		}
+	}
+	return true
+}
+
+// siblingPosition returns 1-based position of element among element siblings walking first/next links of its parent
+func (xr *XMLReader) siblingPosition(node *Element, ofType, last bool) int {
+	nodes, name := xr.tree.nodes, node.data.Name(xr.in)
+	before, after, found := 0, 0, false
+	for i := nodes[node.parent].first; i != -1 && !(found && !last); i = nodes[i].next {
+		sibling := &nodes[i]
+		if !sibling.data.IsElement() || ofType && !bytes.Equal(sibling.data.Name(xr.in), name) {
+			continue
+		}
+		switch {
+		case i == node.idx:
+			found = true
+		case found:
+			after++
+		default:
+			before++
+		}
+	}
+	if last {
+		return after + 1
+	}
+	return before + 1
+}
+
+func (p selectorPseudo) match(pos int) bool {
+	if p.a == 0 {
+		return pos == p.b
+	}
+	n := pos - p.b
+	return n%p.a == 0 && n/p.a >= 0
+}
+
+type selectorParser struct {
+	sel string
+	i   int
+}
+
+func (p *selectorParser) eof() bool {
+	return p.i >= len(p.sel)
+}
+
+func (p *selectorParser) errorf(format string, args ...any) error {
+	return &SelectorError{Selector: p.sel, Offset: p.i, Reason: fmt.Sprintf(format, args...)}
+}
+
+// skipSpace skips whitespaces, returns true if any was found
+func (p *selectorParser) skipSpace() bool {
+	start := p.i
+	for !p.eof() && whitespace[p.sel[p.i]] {
+		p.i++
+	}
+	return p.i > start
+}
+
+// parseChain parses compounds separated by combinators till end of selector or ','
+func (p *selectorParser) parseChain() ([]selectorCompound, error) {
+	var chain []selectorCompound
+	combinator := byte(0)
+	p.skipSpace()
+	for {
+		compound, err := p.parseCompound()
+		if err != nil {
+			return nil, err
+		}
+		compound.combinator = combinator
+		chain = append(chain, compound)
+
+		space := p.skipSpace()
+		if p.eof() || p.sel[p.i] == ',' {
+			return chain, nil
+		}
+		switch ch := p.sel[p.i]; {
+		case ch == '>':
+			combinator = '>'
+			p.i++
+			p.skipSpace()
+		case ch == '+' || ch == '~':
+			return nil, p.errorf("unsupported combinator '%c'", ch)
+		case space:
+			combinator = ' '
+		default:
+			return nil, p.errorf("unexpected '%c'", ch)
+		}
+	}
+}
+
+func (p *selectorParser) parseCompound() (c selectorCompound, err error) {
+	start := p.i
+	if !p.eof() && p.sel[p.i] == '*' {
+		c.name = "*"
+		p.i++
+	} else if end := scanQueryName(p.sel, p.i); end > p.i {
+		c.name = p.sel[p.i:end]
+		p.i = end
+	}
+
+	for !p.eof() {
+		switch p.sel[p.i] {
+		case '#':
+			//id may start with digit like hash token of CSS
+			p.i++
+			end := p.i
+			for end < len(p.sel) {
+				r, size := utf8.DecodeRuneInString(p.sel[end:])
+				if !isNameRune(r) {
+					break
+				}
+				end += size
+			}
+			if end == p.i {
+				return c, p.errorf("missing id")
+			}
+			id := p.sel[p.i:end]
+			p.i = end
+			c.attrs = append(c.attrs, selectorAttr{key: "id", op: "=", value: id})
+			continue
+		case '[':
+			p.i++
+			attr, err := p.parseAttr()
+			if err != nil {
+				return c, err
+			}
+			c.attrs = append(c.attrs, attr)
+			continue
+		case ':':
+			p.i++
+			pseudo, err := p.parsePseudo()
+			if err != nil {
+				return c, err
+			}
+			c.pseudos = append(c.pseudos, pseudo)
+			continue
+		}
+		break
+	}
+
+	if p.i == start {
+		if p.eof() {
+			return c, p.errorf("missing selector")
+		}
+		return c, p.errorf("unexpected '%c'", p.sel[p.i])
+	}
+	return c, nil
+}
+
+func (p *selectorParser) parseName(what string) (string, error) {
+	end := scanQueryName(p.sel, p.i)
+	if end == p.i {
+		return "", p.errorf("missing %s", what)
+	}
+	name := p.sel[p.i:end]
+	p.i = end
+	return name, nil
+}
+
+// parseAttr parses attribute selector following '['
+func (p *selectorParser) parseAttr() (attr selectorAttr, err error) {
+	p.skipSpace()
+	if attr.key, err = p.parseName("attribute name"); err != nil {
+		return attr, err
+	}
+	p.skipSpace()
+	if p.eof() {
+		return attr, p.errorf("missing ']'")
+	}
+	if p.sel[p.i] == ']' {
+		p.i++
+		return attr, nil
+	}
+
+	for _, op := range []string{"=", "^=", "$=", "*="} {
+		if strings.HasPrefix(p.sel[p.i:], op) {
+			attr.op = op
+			break
+		}
+	}
+	if attr.op == "" {
+		return attr, p.errorf("unsupported attribute operator")
+	}
+	p.i += len(attr.op)
+	p.skipSpace()
+
+	if p.eof() {
+		return attr, p.errorf("missing attribute value")
+	}
+	if quote := p.sel[p.i]; quote == '"' || quote == '\'' {
+		end := strings.IndexByte(p.sel[p.i+1:], quote)
+		if end == -1 {
+			return attr, p.errorf("unterminated attribute value")
+		}
+		attr.value = p.sel[p.i+1 : p.i+1+end]
+		p.i += end + 2
+	} else {
+		//unquoted value runs till whitespace or ]
+		end := p.i
+		for end < len(p.sel) && p.sel[end] != ']' && !whitespace[p.sel[end]] {
+			end++
+		}
+		if end == p.i {
+			return attr, p.errorf("missing attribute value")
+		}
+		attr.value = p.sel[p.i:end]
+		p.i = end
+	}
+
+	p.skipSpace()
+	if p.eof() || p.sel[p.i] != ']' {
+		return attr, p.errorf("missing ']'")
+	}
+	p.i++
+	return attr, nil
+}
+
+// parsePseudo parses pseudo class following ':'
+func (p *selectorParser) parsePseudo() (pseudo selectorPseudo, err error) {
+	start := p.i
+	name, err := p.parseName("pseudo class")
+	if err != nil {
+		return pseudo, err
+	}
+	switch name {
+	case "first-child", "first-of-type":
+		pseudo.b = 1
+	case "last-child", "last-of-type":
+		pseudo.b, pseudo.last = 1, true
+	case "nth-child", "nth-of-type", "nth-last-child", "nth-last-of-type":
+		if p.eof() || p.sel[p.i] != '(' {
+			return pseudo, p.errorf("missing argument of :%s", name)
+		}
+		end := strings.IndexByte(p.sel[p.i:], ')')
+		if end == -1 {
+			return pseudo, p.errorf("missing ')'")
+		}
+		var ok bool
+		if pseudo.a, pseudo.b, ok = parseNth(p.sel[p.i+1 : p.i+end]); !ok {
+			p.i++
+			return pseudo, p.errorf("invalid argument of :%s", name)
+		}
+		p.i += end + 1
+		pseudo.last = strings.HasPrefix(name, "nth-last-")
+	default:
+		p.i = start
+		return pseudo, p.errorf("unsupported pseudo class :%s", name)
+	}
+	pseudo.ofType = strings.HasSuffix(name, "-of-type")
+	return pseudo, nil
+}
+
+// parseNth parses an+b argument of :nth-child, odd and even keywords included
+func parseNth(arg string) (a, b int, ok bool) {
+	arg = strings.ToLower(strings.Join(strings.Fields(arg), ""))
+	switch arg {
+	case "odd":
+		return 2, 1, true
+	case "even":
+		return 2, 0, true
+	case "":
+		return 0, 0, false
+	}
+
+	n := strings.IndexByte(arg, 'n')
+	if n == -1 {
+		b, err := strconv.Atoi(arg)
+		return 0, b, err == nil
+	}
+
+	switch coef := arg[:n]; coef {
+	case "", "+":
+		a = 1
+	case "-":
+		a = -1
+	default:
+		var err error
+		if a, err = strconv.Atoi(coef); err != nil {
+			return 0, 0, false
+		}
+	}
+	if rest := arg[n+1:]; rest != "" {
+		if rest[0] != '+' && rest[0] != '-' {
+			return 0, 0, false
+		}
+		var err error
+		if b, err = strconv.Atoi(rest); err != nil {
+			return 0, 0, false
+		}
+	}
+	return a, b, true
+}
@@ -0,0 +1,147 @@
+package fastxml
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var selectorTestXML = []byte(`<VAST>
+	<Ad id="1">
+		<Wrapper>
+			<!-- first tracking -->
+			<Tracking event="start">w1</Tracking>
+			<Tracking event="end">w2</Tracking>
+			<Impression>i1</Impression>
+			<Tracking event="start">w3</Tracking>
+		</Wrapper>
+	</Ad>
+	<Ad id="2">
+		<InLine>
+			<Impression>i2</Impression>
+			<Tracking event="start" url="http://cdn.example.com/start.gif">l1</Tracking>
+			<Creatives><Creative><Tracking event="firstQuartile">l2</Tracking></Creative></Creatives>
+		</InLine>
+	</Ad>
+</VAST>`)
+
+func TestXMLReaderSelect(t *testing.T) {
+	reader := NewXMLReader()
+	if !assert.NoError(t, reader.Parse(selectorTestXML)) {
+		return
+	}
+
+	tests := []struct {
+		name string
+		sel  string
+		want []string //text of selected elements
+	}{
+		{name: "request_example", sel: "Ad > Wrapper Tracking[event=start]:first-child", want: []string{"w1"}},
+		{name: "descendant", sel: "Ad Tracking", want: []string{"w1", "w2", "w3", "l1", "l2"}},
+		{name: "child", sel: "InLine > Tracking", want: []string{"l1"}},
+		{name: "child_chain", sel: "VAST > Ad > * > Impression", want: []string{"i1", "i2"}},
+		{name: "id", sel: "#2 Tracking", want: []string{"l1", "l2"}},
+		{name: "attr_exists", sel: "Tracking[url]", want: []string{"l1"}},
+		{name: "attr_equal_quoted", sel: `Tracking[event="start"]`, want: []string{"w1", "w3", "l1"}},
+		{name: "attr_prefix", sel: "[event^=first]", want: []string{"l2"}},
+		{name: "attr_suffix", sel: "Tracking[url$='.gif']", want: []string{"l1"}},
+		{name: "attr_contains", sel: "Tracking[url*=example]", want: []string{"l1"}},
+		{name: "attr_empty_contains", sel: "Tracking[event*='']", want: nil},
+		{name: "first_child", sel: "Wrapper > :first-child", want: []string{"w1"}},
+		{name: "last_child", sel: "Wrapper > :last-child", want: []string{"w3"}},
+		{name: "nth_child", sel: "Wrapper > :nth-child(3)", want: []string{"i1"}},
+		{name: "nth_child_odd", sel: "Wrapper > *:nth-child(odd)", want: []string{"w1", "i1"}},
+		{name: "nth_child_formula", sel: "Wrapper > :nth-child(-n + 2)", want: []string{"w1", "w2"}},
+		{name: "nth_last_child", sel: "Wrapper > :nth-last-child(2)", want: []string{"i1"}},
+		{name: "first_of_type", sel: "Impression:first-of-type, InLine > Tracking:first-of-type", want: []string{"i1", "i2", "l1"}},
+		{name: "nth_of_type", sel: "Wrapper > Tracking:nth-of-type(2n+1)", want: []string{"w1", "w3"}},
+		{name: "last_of_type", sel: "Wrapper > Tracking:last-of-type", want: []string{"w3"}},
+		{name: "list_in_document_order", sel: "Impression, Wrapper > Tracking:first-child", want: []string{"w1", "i1", "i2"}},
+		{name: "no_match", sel: "InLine > Wrapper", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodes, err := reader.Select(tt.sel)
+			if !assert.NoError(t, err) {
+				return
+			}
+			var got []string
+			for _, node := range nodes {
+				got = append(got, reader.Text(node))
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSelectorReuse(t *testing.T) {
+	s := MustCompileSelector("Tracking[event=start]")
+	reader := NewXMLReader()
+	if !assert.NoError(t, reader.Parse(selectorTestXML)) {
+		return
+	}
+	inline := reader.FindFirstDescendant(nil, "InLine")
+	nodes := reader.SelectWith(s, inline)
+	if assert.Len(t, nodes, 1) {
+		assert.Equal(t, "l1", reader.Text(nodes[0]))
+	}
+
+	other := NewXMLReader()
+	if !assert.NoError(t, other.Parse([]byte(`<a><Tracking event="start"/><Tracking/></a>`))) {
+		return
+	}
+	assert.Len(t, other.SelectWith(s, nil), 1)
+}
+
+func TestCompileSelectorErrors(t *testing.T) {
+	tests := []struct {
+		sel string
+		err string
+	}{
+		{sel: "", err: `invalid selector: missing selector at offset 0 in ""`},
+		{sel: "Ad >", err: `invalid selector: missing selector at offset 4 in "Ad >"`},
+		{sel: "Ad + Wrapper", err: `invalid selector: unsupported combinator '+' at offset 3 in "Ad + Wrapper"`},
+		{sel: "Ad[id~=1]", err: `invalid selector: unsupported attribute operator at offset 5 in "Ad[id~=1]"`},
+		{sel: "Ad[id=1", err: `invalid selector: missing ']' at offset 7 in "Ad[id=1"`},
+		{sel: "Ad[id='1]", err: `invalid selector: unterminated attribute value at offset 6 in "Ad[id='1]"`},
+		{sel: "Ad:hover", err: `invalid selector: unsupported pseudo class :hover at offset 3 in "Ad:hover"`},
+		{sel: "Ad:nth-child(x)", err: `invalid selector: invalid argument of :nth-child at offset 13 in "Ad:nth-child(x)"`},
+		{sel: "Ad:nth-child", err: `invalid selector: missing argument of :nth-child at offset 12 in "Ad:nth-child"`},
+		{sel: "Ad, ,", err: `invalid selector: unexpected ',' at offset 4 in "Ad, ,"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sel, func(t *testing.T) {
+			_, err := CompileSelector(tt.sel)
+			assert.ErrorIs(t, err, ErrInvalidSelector)
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+}
+
+func Test_parseNth(t *testing.T) {
+	tests := []struct {
+		arg  string
+		a, b int
+		ok   bool
+	}{
+		{arg: "odd", a: 2, b: 1, ok: true},
+		{arg: "even", a: 2, b: 0, ok: true},
+		{arg: "3", a: 0, b: 3, ok: true},
+		{arg: "n", a: 1, b: 0, ok: true},
+		{arg: "-n+3", a: -1, b: 3, ok: true},
+		{arg: " 2n - 1 ", a: 2, b: -1, ok: true},
+		{arg: "+3n+2", a: 3, b: 2, ok: true},
+		{arg: "2n1", ok: false},
+		{arg: "x", ok: false},
+		{arg: "", ok: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.arg, func(t *testing.T) {
+			a, b, ok := parseNth(tt.arg)
+			assert.Equal(t, tt.ok, ok)
+			if tt.ok {
+				assert.Equal(t, []int{tt.a, tt.b}, []int{a, b})
+			}
+		})
+	}
+}
@@ -0,0 +1,296 @@
+package fastxml
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const defaultStreamBufferSize = 4096
+
+// ErrTokenTooLarge is returned by StreamTokenizer when single xml token doesn't fit into its buffer
+var ErrTokenTooLarge = errors.New("xml token larger than stream buffer")
+
+type streamFrame struct {
+	element Element
+	tag     int  //offset of raw start tag in StreamTokenizer.tags
+	leaf    bool //no child element found yet
+
+	line, column int //position of start tag, its data may not be buffered anymore
+}
+
+/*
+StreamTokenizer reads xml from io.Reader using fixed size buffer and emits the same
+TokenHandler callbacks as XMLTokenizer. Only the start tags of currently open elements
+and the content of innermost leaf element (if it fits into buffer) are kept in memory.
+Element offsets are relative to the whole stream, use StartTag, Text and Bytes to read
+buffered data while inside callback.
+*/
+type StreamTokenizer struct {
+	r    io.Reader
+	buf  []byte
+	base int //stream offset of buf[0]
+	keep int //buffer index retained for innermost leaf element, -1 if none
+	eof  bool
+
+	counted   int //stream offset up to which new lines are counted
+	lines     int //new lines before counted offset
+	lineStart int //stream offset of first byte after last new line before counted offset
+
+	tags   []byte
+	s      stack[streamFrame]
+	closed *streamFrame //element currently reported to callback
+}
+
+func NewStreamTokenizer(r io.Reader, bufSize int) *StreamTokenizer {
+	if bufSize <= 0 {
+		bufSize = defaultStreamBufferSize
+	}
+	return &StreamTokenizer{
+		r:   r,
+		buf: make([]byte, 0, bufSize),
+	}
+}
+
+func (st *StreamTokenizer) reset() {
+	st.buf = st.buf[:0]
+	st.base, st.keep = 0, -1
+	st.eof = false
+	st.counted, st.lines, st.lineStart = 0, 0, 0
+	st.tags = st.tags[:0]
+	st.s.data = st.s.data[:0]
+	st.closed = nil
+}
+
+func (st *StreamTokenizer) Parse(cb TokenHandler) error {
+	st.reset()
+
+	for i := 0; ; {
+		//search next token in buffered data
+		j := bytes.IndexByte(st.buf[i:], '<')
+		if j == -1 {
+			i = len(st.buf)
+			if st.eof {
+				break
+			}
+			shift, err := st.fill(i)
+			if err != nil {
+				return err
+			}
+			i -= shift
+			continue
+		}
+		i += j
+
+		//get token type and endindex, both may require more data
+		ttype := getTokenType(st.buf, i+1)
+		endIndex, inlineToken := getTokenEndIndex(st.buf, i+1, ttype)
+		if endIndex == -1 {
+			if st.eof {
+				return st.syntaxError(st.base+i, ttype, unterminatedReason(ttype))
+			}
+			shift, err := st.fill(i)
+			if err != nil {
+				return err
+			}
+			i -= shift
+			continue
+		}
+
+		if inlineToken {
+			ttype = endXMLToken
+		}
+
+		if ttype == startXMLToken || inlineToken {
+			//parent element is not a leaf anymore
+			if top := st.s.peek(); top != nil {
+				top.leaf = false
+			}
+		}
+
+		if ttype == startXMLToken {
+			element := Element{
+				data: XMLToken{
+					start: xmlTagIndex{si: st.base + i, ei: st.base + endIndex},
+				},
+				first: -1, last: -1, next: -1,
+			}
+			nsi, nei := getTokenNameIndex(st.buf, i+1)
+			element.data.name = xmlTagIndex{si: st.base + nsi, ei: st.base + nei}
+
+			frame := streamFrame{element: element, tag: len(st.tags), leaf: true}
+			frame.line, frame.column = st.position(st.base + i)
+			st.s.push(frame)
+			st.tags = append(st.tags, st.buf[i:endIndex]...)
+			st.keep = i
+		} else if ttype == endXMLToken {
+			var frame *streamFrame
+
+			if inlineToken {
+				frame = &streamFrame{
+					element: Element{
+						data: XMLToken{
+							start: xmlTagIndex{si: st.base + i, ei: st.base + endIndex},
+						},
+						first: -1, last: -1, next: -1,
+					},
+					tag:  -1,
+					leaf: true,
+				}
+				nsi, nei := getTokenNameIndex(st.buf, i+1)
+				frame.element.data.name = xmlTagIndex{si: st.base + nsi, ei: st.base + nei}
+			} else {
+				frame = st.s.pop()
+			}
+
+			if frame == nil {
+				esi, eei := getTokenNameIndex(st.buf, i+2)
+				return st.syntaxError(st.base+i, endXMLToken, "unexpected end tag </%s>", st.buf[esi:eei])
+			}
+			frame.element.data.end = xmlTagIndex{si: st.base + i, ei: st.base + endIndex}
+
+			name := st.name(frame)
+			if !inlineToken {
+				esi, eei := getTokenNameIndex(st.buf, i+2)
+				if !bytes.Equal(name, st.buf[esi:eei]) {
+					return st.syntaxError(st.base+i, endXMLToken, "mismatched end tag </%s> for <%s>", st.buf[esi:eei], name)
+				}
+			}
+
+			if cb != nil {
+				st.closed = frame
+				var parent *Element
+				if top := st.s.peek(); top != nil {
+					parent = &top.element
+				}
+				cb(string(name), parent, frame.element)
+				st.closed = nil
+			}
+
+			if frame.tag != -1 {
+				st.tags = st.tags[:frame.tag]
+			}
+			st.keep = -1
+		}
+		i = endIndex
+	}
+
+	if st.s.len() != 0 {
+		open := st.s.peek()
+		return &SyntaxError{
+			Offset: open.element.data.start.si,
+			Line:   open.line,
+			Column: open.column,
+			Token:  startXMLToken,
+			Reason: fmt.Sprintf("unclosed element <%s> at EOF", st.name(open)),
+		}
+	}
+	return nil
+}
+
+// fill discards data before index from buffer and reads more data from reader, returns discarded length
+func (st *StreamTokenizer) fill(index int) (int, error) {
+	drop := index
+	if st.keep != -1 && st.keep < drop {
+		drop = st.keep
+	}
+	if drop == 0 && len(st.buf) == cap(st.buf) {
+		if st.keep == -1 || index == 0 {
+			return 0, ErrTokenTooLarge
+		}
+		//leaf element content doesn't fit into buffer, stop retaining it
+		st.keep = -1
+		drop = index
+	}
+
+	if drop > 0 {
+		if st.counted < st.base+drop {
+			st.position(st.base + drop)
+		}
+		st.buf = st.buf[:copy(st.buf, st.buf[drop:])]
+		st.base += drop
+		if st.keep != -1 {
+			st.keep -= drop
+		}
+	}
+
+	n, err := io.ReadAtLeast(st.r, st.buf[len(st.buf):cap(st.buf)], 1)
+	st.buf = st.buf[:len(st.buf)+n]
+	if err == io.EOF {
+		st.eof = true
+		err = nil
+	}
+	return drop, err
+}
+
+// name returns element name from buffered start tag
+func (st *StreamTokenizer) name(frame *streamFrame) []byte {
+	token := frame.element.data
+	if frame.tag == -1 {
+		return st.Bytes(token.name.si, token.name.ei)
+	}
+	offset := frame.tag - token.start.si
+	return st.tags[token.name.si+offset : token.name.ei+offset]
+}
+
+func (st *StreamTokenizer) syntaxError(offset int, ttype xmlTokenType, format string, args ...any) *SyntaxError {
+	err := newSyntaxError(st.buf, offset-st.base, ttype, format, args...)
+	err.Offset = offset
+	err.Line, err.Column = st.position(offset)
+	return err
+}
+
+// position returns line and column of buffered stream offset, new lines are counted only once as offsets increase
+func (st *StreamTokenizer) position(offset int) (line, column int) {
+	if offset > st.counted {
+		counted := st.buf[st.counted-st.base : offset-st.base]
+		if n := bytes.Count(counted, []byte{'\n'}); n > 0 {
+			st.lines += n
+			st.lineStart = st.counted + bytes.LastIndexByte(counted, '\n') + 1
+		}
+		st.counted = offset
+	}
+	return st.lines + 1, offset - st.lineStart + 1
+}
+
+// Bytes returns stream data between si and ei offsets iff it is still buffered
+func (st *StreamTokenizer) Bytes(si, ei int) []byte {
+	if si < st.base || ei > st.base+len(st.buf) || si > ei {
+		return nil
+	}
+	return st.buf[si-st.base : ei-st.base]
+}
+
+// StartTag returns raw start tag of element reported to callback or any of its open ancestors
+func (st *StreamTokenizer) StartTag(e *Element) []byte {
+	if e == nil {
+		return nil
+	}
+	if e.data.IsInline() {
+		return st.Bytes(e.data.start.si, e.data.start.ei)
+	}
+	frames := st.s.data
+	if st.closed != nil {
+		frames = append(frames[:len(frames):len(frames)], *st.closed)
+	}
+	for i := len(frames) - 1; i >= 0; i-- {
+		if frames[i].element.data.start.si == e.data.start.si {
+			return st.tags[frames[i].tag : frames[i].tag+e.data.start.ei-e.data.start.si]
+		}
+	}
+	return nil
+}
+
+// Text returns text of leaf element reported to callback, nil if it is not buffered anymore
+func (st *StreamTokenizer) Text(e *Element) []byte {
+	if e == nil || e.data.IsInline() || st.closed == nil || !st.closed.leaf ||
+		st.closed.element.data.start.si != e.data.start.si {
+		return nil
+	}
+	if e.data.start.si < st.base || e.data.end.si > st.base+len(st.buf) {
+		return nil
+	}
+	si, ei, _ := _trimCDATA(st.buf, e.data.start.ei-st.base, e.data.end.si-st.base)
+	return st.buf[si:ei]
+}
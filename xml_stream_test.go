@@ -0,0 +1,129 @@
+package fastxml
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mockStreamHandler struct {
+	st     *StreamTokenizer
+	names  []string
+	tokens []XMLToken
+	tags   []string
+	texts  []string
+}
+
+func (r *mockStreamHandler) append(name string, parent *Element, child Element) {
+	r.names = append(r.names, name)
+	r.tokens = append(r.tokens, child.data)
+	r.tags = append(r.tags, string(r.st.StartTag(&child)))
+	r.texts = append(r.texts, string(r.st.Text(&child)))
+}
+
+func TestStreamTokenizer(t *testing.T) {
+	in := []byte(xml)
+
+	//expected tokens from in-memory tokenizer
+	expected := mockTokenHandler{}
+	assert.NoError(t, NewXMLTokenizer().Parse(in, expected.append))
+
+	for _, bufSize := range []int{320, 512, 4096} {
+		handler := &mockStreamHandler{}
+		handler.st = NewStreamTokenizer(iotest.HalfReader(strings.NewReader(xml)), bufSize)
+		assert.NoError(t, handler.st.Parse(handler.append))
+
+		if !assert.Equal(t, len(expected.tokens), len(handler.tokens), "bufSize:%d", bufSize) {
+			continue
+		}
+		for i, token := range expected.tokens {
+			assert.Equal(t, string(token.Name(in)), handler.names[i])
+			assert.Equal(t, token.start, handler.tokens[i].start)
+			assert.Equal(t, token.end, handler.tokens[i].end)
+
+			si, ei := token.StartTagOffset()
+			assert.Equal(t, string(in[si:ei]), handler.tags[i])
+			if token.end.ei-token.start.si <= bufSize && !strings.Contains(string(token.Text(in)), "<") {
+				//leaf text is available only when it fits into buffer
+				assert.Equal(t, string(token.Text(in)), handler.texts[i])
+			} else {
+				assert.Empty(t, handler.texts[i])
+			}
+		}
+	}
+}
+
+func TestStreamTokenizerParent(t *testing.T) {
+	doc := `<Ad id="1"><InLine><Creatives><!-- first creative --><Creative id="c1"><MediaFile>http://m.com/a.mp4</MediaFile></Creative></Creatives></InLine></Ad>`
+	st := NewStreamTokenizer(iotest.OneByteReader(strings.NewReader(doc)), 48)
+
+	var parents []string
+	err := st.Parse(func(name string, parent *Element, child Element) {
+		if parent != nil {
+			parents = append(parents, name+":"+string(st.StartTag(parent)))
+		}
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		`MediaFile:<Creative id="c1">`,
+		`Creative:<Creatives>`,
+		`Creatives:<InLine>`,
+		`InLine:<Ad id="1">`,
+	}, parents)
+}
+
+func TestStreamTokenizerErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		bufSize int
+		want    error
+	}{
+		{
+			name:    "token_too_large",
+			input:   `<a><b k="` + strings.Repeat("v", 64) + `"/></a>`,
+			bufSize: 32,
+			want:    ErrTokenTooLarge,
+		},
+		{
+			name:    "mismatched_end_tag",
+			input:   "<a>\n" + strings.Repeat(" ", 100) + "<b>\n</c></a>",
+			bufSize: 16,
+			want:    &SyntaxError{Offset: 108, Line: 3, Column: 1, Token: endXMLToken, Reason: "mismatched end tag </c> for <b>"},
+		},
+		{
+			name:    "unterminated_comment",
+			input:   "<a><!-- comment",
+			bufSize: 16,
+			want:    &SyntaxError{Offset: 3, Line: 1, Column: 4, Token: commentsXMLToken, Reason: "unterminated comment"},
+		},
+		{
+			name:    "unclosed_element",
+			input:   "<a><b></b>",
+			bufSize: 16,
+			want:    &SyntaxError{Offset: 0, Line: 1, Column: 1, Token: startXMLToken, Reason: "unclosed element <a> at EOF"},
+		},
+		{
+			name:    "unclosed_element_discarded",
+			input:   "<a>\n  <b>" + strings.Repeat("\n<c/>", 20),
+			bufSize: 16,
+			want:    &SyntaxError{Offset: 6, Line: 2, Column: 3, Token: startXMLToken, Reason: "unclosed element <b> at EOF"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewStreamTokenizer(strings.NewReader(tt.input), tt.bufSize).Parse(nil)
+			var syntaxErr *SyntaxError
+			if errors.As(err, &syntaxErr) {
+				assert.Equal(t, tt.want, syntaxErr)
+				//in memory tokenizer reports the same position
+				assert.Equal(t, tt.want, NewXMLTokenizer().Parse([]byte(tt.input), nil))
+				return
+			}
+			assert.Equal(t, tt.want, err)
+		})
+	}
+}
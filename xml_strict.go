@@ -0,0 +1,260 @@
+package fastxml
+
+import (
+	"bytes"
+	"unicode/utf8"
+)
+
+// strictState tracks document level well-formedness constraints in strict mode
+type strictState struct {
+	last    int        //end of previous token
+	root    bool       //root element started
+	doctype bool       //DOCTYPE found
+	open    stack[int] //start offsets of open elements, used by ParseHandler
+
+	keys  []xmlTagIndex       //attribute names of checked start tag
+	names map[string]struct{} //attribute names of checked start tag with more than maxLinearAttrs attributes
+}
+
+// maxLinearAttrs is number of attributes compared pairwise for duplicates, more attributes are looked up in map
+const maxLinearAttrs = 8
+
+// reset prepares state for new document, buffers are reused
+func (s *strictState) reset() {
+	*s = strictState{open: stack[int]{data: s.open.data[:0]}, keys: s.keys[:0], names: s.names}
+}
+
+// duplicate reports whether attribute name key was already found in checked start tag, keys are reset for each tag
+func (s *strictState) duplicate(in []byte, key xmlTagIndex) bool {
+	name := in[key.si:key.ei]
+	if len(s.keys) < maxLinearAttrs {
+		for _, k := range s.keys {
+			if bytes.Equal(in[k.si:k.ei], name) {
+				return true
+			}
+		}
+		s.keys = append(s.keys, key)
+		return false
+	}
+
+	if len(s.keys) == maxLinearAttrs {
+		//too many attributes for pairwise comparison
+		if s.names == nil {
+			s.names = make(map[string]struct{})
+		}
+		for name := range s.names {
+			delete(s.names, name)
+		}
+		for _, k := range s.keys {
+			s.names[string(in[k.si:k.ei])] = struct{}{}
+		}
+	}
+	if _, ok := s.names[string(name)]; ok {
+		return true
+	}
+	s.names[string(name)] = struct{}{}
+	s.keys = append(s.keys, key)
+	return false
+}
+
+// checkChars rejects invalid UTF-8 and characters not allowed by XML 1.0 Char production
+func checkChars(in []byte) error {
+	for i := 0; i < len(in); {
+		if ch := in[i]; ch < utf8.RuneSelf {
+			if ch < 0x20 && !whitespace[ch] {
+				return newSyntaxError(in, i, textToken, "illegal character U+%04X", ch)
+			}
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRune(in[i:])
+		if r == utf8.RuneError && size == 1 {
+			return newSyntaxError(in, i, textToken, "invalid UTF-8 sequence")
+		}
+		if !isXMLChar(r) {
+			return newSyntaxError(in, i, textToken, "illegal character U+%04X", r)
+		}
+		i += size
+	}
+	return nil
+}
+
+// checkReferences validates character and entity references in in[si:ei]
+func (sp *XMLTokenizer) checkReferences(in []byte, si, ei int, ttype xmlTokenType) error {
+	for i := si; i < ei; i++ {
+		if in[i] != '&' {
+			continue
+		}
+		if i+1 < ei && in[i+1] == '#' {
+			_, n, reason := parseCharRef(in[i:ei])
+			if n == 0 {
+				return newSyntaxError(in, i, ttype, reason)
+			}
+			i += n - 1
+			continue
+		}
+		end := scanName(in[:ei], i+1)
+		if end == i+1 || end >= ei || in[end] != ';' {
+			return newSyntaxError(in, i, ttype, "bare '&' not allowed")
+		}
+		if _, ok := predefinedEntity(in[i+1 : end]); !ok && sp.entities.lookup(in[i+1:end]) == nil {
+			return newSyntaxError(in, i, ttype, "undefined entity &%s;", in[i+1:end])
+		}
+		i = end
+	}
+	return nil
+}
+
+// checkText validates character data in[si:ei] found at depth
+func (sp *XMLTokenizer) checkText(in []byte, si, ei, depth int) error {
+	if depth == 0 {
+		for i := si; i < ei; i++ {
+			if !whitespace[in[i]] {
+				return newSyntaxError(in, i, textToken, "content outside root element")
+			}
+		}
+		return nil
+	}
+	if i := bytes.Index(in[si:ei], cdataEnd); i != -1 {
+		return newSyntaxError(in, si+i, textToken, "']]>' not allowed in text")
+	}
+	return sp.checkReferences(in, si, ei, textToken)
+}
+
+// checkAttributes validates attribute list in[si:ei] of start tag
+func (sp *XMLTokenizer) checkAttributes(in []byte, si, ei int) error {
+	sp.strict.keys = sp.strict.keys[:0]
+	for i := si; ; {
+		space := i
+		for ; i < ei && whitespace[in[i]]; i++ {
+		}
+		if i >= ei {
+			return nil
+		}
+		if i == space {
+			return newSyntaxError(in, i, startXMLToken, "missing whitespace before attribute")
+		}
+
+		key := xmlTagIndex{si: i, ei: scanName(in[:ei], i)}
+		if !isQName(in[key.si:key.ei]) {
+			return newSyntaxError(in, i, startXMLToken, "invalid attribute name")
+		}
+		if sp.strict.duplicate(in, key) {
+			return newSyntaxError(in, key.si, startXMLToken, "duplicate attribute %s", in[key.si:key.ei])
+		}
+
+		for i = key.ei; i < ei && whitespace[in[i]]; i++ {
+		}
+		if i >= ei || in[i] != '=' {
+			return newSyntaxError(in, key.si, startXMLToken, "attribute %s without value", in[key.si:key.ei])
+		}
+		for i++; i < ei && whitespace[in[i]]; i++ {
+		}
+		if i >= ei || (in[i] != '"' && in[i] != '\'') {
+			return newSyntaxError(in, i, startXMLToken, "unquoted value of attribute %s", in[key.si:key.ei])
+		}
+		end := bytes.IndexByte(in[i+1:ei], in[i])
+		if end == -1 {
+			return newSyntaxError(in, i, startXMLToken, "unterminated value of attribute %s", in[key.si:key.ei])
+		}
+		vsi, vei := i+1, i+1+end
+		if lt := bytes.IndexByte(in[vsi:vei], '<'); lt != -1 {
+			return newSyntaxError(in, vsi+lt, startXMLToken, "'<' not allowed in value of attribute %s", in[key.si:key.ei])
+		}
+		if err := sp.checkReferences(in, vsi, vei, startXMLToken); err != nil {
+			return err
+		}
+		i = vei + 1
+	}
+}
+
+// checkToken validates token in[si:ei] found at depth against XML 1.0 well-formedness constraints
+func (sp *XMLTokenizer) checkToken(in []byte, si, ei int, ttype xmlTokenType, inline bool, depth int) error {
+	st := &sp.strict
+	if err := sp.checkText(in, st.last, si, depth); err != nil {
+		return err
+	}
+	st.last = ei
+
+	switch ttype {
+	case startXMLToken:
+		if depth == 0 {
+			if st.root {
+				return newSyntaxError(in, si, startXMLToken, "multiple root elements")
+			}
+			st.root = true
+		}
+		nsi, nei := si+1, scanName(in, si+1)
+		if !isQName(in[nsi:nei]) {
+			return newSyntaxError(in, si, startXMLToken, "invalid element name")
+		}
+		end := ei - 1
+		if inline {
+			end--
+		}
+		return sp.checkAttributes(in, nei, end)
+	case endXMLToken:
+		nei := scanName(in, si+2)
+		for i := nei; i < ei-1; i++ {
+			if !whitespace[in[i]] {
+				return newSyntaxError(in, i, endXMLToken, "unexpected character in end tag")
+			}
+		}
+	case commentsXMLToken:
+		if ei-si < 7 {
+			return newSyntaxError(in, si, commentsXMLToken, "invalid comment")
+		}
+		if text := in[si+4 : ei-3]; bytes.Contains(text, []byte("--")) || bytes.HasSuffix(text, []byte("-")) {
+			return newSyntaxError(in, si, commentsXMLToken, "'--' not allowed in comment")
+		}
+	case processingXMLToken:
+		end := scanName(in, si+2)
+		if end == si+2 {
+			return newSyntaxError(in, si, processingXMLToken, "invalid processing instruction target")
+		}
+		if bytes.EqualFold(in[si+2:end], []byte("xml")) && si != 0 {
+			return newSyntaxError(in, si, processingXMLToken, "xml declaration not at start of document")
+		}
+	case cdataXMLToken:
+		if depth == 0 {
+			return newSyntaxError(in, si, cdataXMLToken, "content outside root element")
+		}
+	case doctypeXMLToken:
+		if st.doctype || st.root {
+			return newSyntaxError(in, si, doctypeXMLToken, "DOCTYPE not allowed here")
+		}
+		st.doctype = true
+	default:
+		return newSyntaxError(in, si, textToken, "'<' not allowed in text")
+	}
+	return nil
+}
+
+// isQName reports whether name is local name or prefix:local with both parts non empty
+func isQName(name []byte) bool {
+	if len(name) == 0 {
+		return false
+	}
+	colon := bytes.IndexByte(name, ':')
+	return colon == -1 || colon > 0 && colon < len(name)-1 && bytes.IndexByte(name[colon+1:], ':') == -1
+}
+
+// checkEndTagQName compares qualified names of start tag at ssi and its end tag at esi, local names are already equal
+func checkEndTagQName(in []byte, ssi, esi int) error {
+	start, end := in[ssi+1:scanName(in, ssi+1)], in[esi+2:scanName(in, esi+2)]
+	if !bytes.Equal(start, end) {
+		return newSyntaxError(in, esi, endXMLToken, "mismatched end tag </%s> for <%s>", end, start)
+	}
+	return nil
+}
+
+// checkEOF validates trailing content of document
+func (sp *XMLTokenizer) checkEOF(in []byte) error {
+	if err := sp.checkText(in, sp.strict.last, len(in), 0); err != nil {
+		return err
+	}
+	if !sp.strict.root {
+		return newSyntaxError(in, len(in), startXMLToken, "missing root element")
+	}
+	return nil
+}
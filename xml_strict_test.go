@@ -0,0 +1,83 @@
+package fastxml
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStrictMode(t *testing.T) {
+	tests := []struct {
+		name   string
+		in     string
+		reason string
+		offset int
+	}{
+		{name: "well_formed", in: "<?xml version=\"1.0\"?>\n<!DOCTYPE a [<!ENTITY e \"v\">]>\n<!-- c --><a x=\"1\" y='&e;&#65;'><b/>t&amp;&e;<![CDATA[<&]]></a>\n"},
+		{name: "duplicate_attribute", in: `<a x="1" x="2"/>`, reason: "duplicate attribute x", offset: 9},
+		{name: "many_attributes", in: `<a` + testAttrs(20) + `><b` + testAttrs(20) + `/></a>`},
+		{name: "duplicate_of_many_attributes", in: `<a` + testAttrs(12) + ` a3="1"/>`, reason: "duplicate attribute a3", offset: 89},
+		{name: "duplicate_after_many_attributes", in: `<a` + testAttrs(12) + `><b a1="1"` + testAttrs(3) + `/></a>`, reason: "duplicate attribute a1", offset: 106},
+		{name: "unquoted_attribute", in: `<a x=1/>`, reason: "unquoted value of attribute x", offset: 5},
+		{name: "attribute_without_value", in: `<a x y="1"/>`, reason: "attribute x without value", offset: 3},
+		{name: "missing_attribute_whitespace", in: `<a x="1"y="2"/>`, reason: "missing whitespace before attribute", offset: 8},
+		{name: "lt_in_attribute", in: `<a x="a<b"/>`, reason: "'<' not allowed in value of attribute x", offset: 7},
+		{name: "bare_amp_in_attribute", in: `<a x="a&b"/>`, reason: "bare '&' not allowed", offset: 7},
+		{name: "content_after_root", in: `<a/>text`, reason: "content outside root element", offset: 4},
+		{name: "content_before_root", in: `text<a/>`, reason: "content outside root element", offset: 0},
+		{name: "multiple_roots", in: `<a/><b/>`, reason: "multiple root elements", offset: 4},
+		{name: "missing_root", in: `<!-- c -->`, reason: "missing root element", offset: 10},
+		{name: "lt_in_text", in: `<a>1 < 2 ></a>`, reason: "'<' not allowed in text", offset: 5},
+		{name: "bare_amp_in_text", in: `<a>AT&T</a>`, reason: "bare '&' not allowed", offset: 5},
+		{name: "undefined_entity", in: `<a>&nbsp;</a>`, reason: "undefined entity &nbsp;", offset: 3},
+		{name: "invalid_char_ref", in: `<a>&#0;</a>`, reason: "invalid character reference &#0;", offset: 3},
+		{name: "overflow_char_ref", in: `<a>&#x10000003C;</a>`, reason: "invalid character reference &#x10000003C;", offset: 3},
+		{name: "cdata_end_in_text", in: `<a>]]></a>`, reason: "']]>' not allowed in text", offset: 3},
+		{name: "control_character", in: "<a>\x01</a>", reason: "illegal character U+0001", offset: 3},
+		{name: "invalid_utf8", in: "<a>\xff</a>", reason: "invalid UTF-8 sequence", offset: 3},
+		{name: "double_hyphen_in_comment", in: `<a><!-- a -- b --></a>`, reason: "'--' not allowed in comment", offset: 3},
+		{name: "xml_declaration_not_at_start", in: ` <?xml version="1.0"?><a/>`, reason: "xml declaration not at start of document", offset: 1},
+		{name: "doctype_after_root", in: `<a/><!DOCTYPE a>`, reason: "DOCTYPE not allowed here", offset: 4},
+		{name: "prefix_mismatch", in: `<x:a xmlns:x="u" xmlns:y="u"></y:a>`, reason: "mismatched end tag </y:a> for <x:a>", offset: 29},
+		{name: "missing_end_prefix", in: `<x:a xmlns:x="u"></a>`, reason: "mismatched end tag </a> for <x:a>", offset: 17},
+		{name: "empty_prefix", in: `<:a/>`, reason: "'<' not allowed in text", offset: 0},
+		{name: "empty_local_name", in: `<a:/>`, reason: "invalid element name", offset: 0},
+		{name: "multiple_colons", in: `<a:b:c/>`, reason: "invalid element name", offset: 0},
+		{name: "attribute_empty_prefix", in: `<a :b="1"/>`, reason: "invalid attribute name", offset: 3},
+		{name: "attribute_empty_local_name", in: `<a b:="1"/>`, reason: "invalid attribute name", offset: 3},
+		{name: "end_tag_attribute", in: `<a></a x="1">`, reason: "unexpected character in end tag", offset: 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewXMLReaderWithOptions(ParseOptions{Mode: StrictMode}).Parse([]byte(tt.in))
+			if tt.reason == "" {
+				assert.NoError(t, err)
+				return
+			}
+			var serr *SyntaxError
+			if assert.True(t, errors.As(err, &serr), "error: %v", err) {
+				assert.Equal(t, tt.reason, serr.Reason)
+				assert.Equal(t, tt.offset, serr.Offset)
+			}
+		})
+	}
+}
+
+// testAttrs returns n distinct attributes a0="1" a1="1" ...
+func testAttrs(n int) string {
+	sb := strings.Builder{}
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&sb, ` a%d="1"`, i)
+	}
+	return sb.String()
+}
+
+func TestStrictModeDefaultAccepts(t *testing.T) {
+	//default mode stays permissive
+	for _, in := range []string{`<a x="1" x="2"/>`, `<a/>text`, `<a>AT&T</a>`, `<a/><b/>`} {
+		assert.NoError(t, NewXMLReader().Parse([]byte(in)), in)
+	}
+}
@@ -2,198 +2,435 @@ package fastxml
 
 import (
 	"bytes"
-	"fmt"
 )
 
-var errInvalidXML = fmt.Errorf("invalid xml")
-
 type Element = treeNode
 type TokenHandler func(string, *Element, Element)
 
-type XMLTokenizer struct{}
+type XMLTokenizer struct {
+	opts     ParseOptions
+	limits   bool //any of the opts limits is set
+	elements int  //elements found in current document
+	warnings []SyntaxError
+	strict   strictState
+	s        stack[Element]       //open elements
+	xp       stack[xpathFrame]    //xpath rule nodes active for open elements
+	states   []xpathState         //rule nodes referred by xp frames
+	dec      Decoder              //used by ParseHandler
+	ns       []nsBinding          //xmlns declarations of last parsed document
+	entities entityTable          //entities declared in DOCTYPE of last parsed document
+	names    *[cachedNames]string //element names reported to TokenHandler, reused between documents
+}
+
+const (
+	cachedNames      = 256 //names cache is direct mapped, it doesn't grow with untrusted input
+	maxCachedNameLen = 64
+)
 
 func NewXMLTokenizer() *XMLTokenizer {
 	return &XMLTokenizer{}
 }
 
+func NewXMLTokenizerWithOptions(opts ParseOptions) *XMLTokenizer {
+	return &XMLTokenizer{
+		opts:   opts,
+		limits: opts.hasLimits(),
+	}
+}
+
+// Warnings returns recoveries done while parsing last document in lenient mode, returned slice is not reused by next parse
+func (sp *XMLTokenizer) Warnings() []SyntaxError {
+	return sp.warnings
+}
+
 func (sp *XMLTokenizer) Parse(in []byte, cb TokenHandler) error {
-	/*
-		TODO:
-		1. get s from pool,
-		2. get xp from pool, iff xp.path present
-		3. adding defer to put stack and xp into pool back
-	*/
-	var s stack[Element]
+	return sp.parse(in, nil, cb)
+}
+
+func (sp *XMLTokenizer) ParseWithXPath(in []byte, ixpath *xpath, cb TokenHandler) error {
+	return sp.parse(in, ixpath, cb)
+}
+
+func (sp *XMLTokenizer) parse(in []byte, ixpath *xpath, cb TokenHandler) error {
+	//stacks are reused between documents
+	s, xp := &sp.s, &sp.xp
+	s.data, xp.data, sp.states = s.data[:0], xp.data[:0], sp.states[:0]
+
+	sp.ns = sp.ns[:0]
+	sp.entities.reset(in)
+	sp.elements = 0
+	sp.warnings = nil //slice returned by Warnings is owned by caller
+
+	if sp.opts.MaxDocumentSize > 0 && len(in) > sp.opts.MaxDocumentSize {
+		return &LimitError{Limit: DocumentSizeLimit, Max: sp.opts.MaxDocumentSize, Offset: sp.opts.MaxDocumentSize}
+	}
+
+	if sp.opts.Mode == StrictMode {
+		sp.strict.reset()
+		if err := checkChars(in); err != nil {
+			return err
+		}
+	}
 
+	text := 0 //start of text after previous token
 	for i := 0; i < len(in); {
-		if in[i] == '<' {
-			//get token type
-			ttype := getTokenType(in, i+1)
+		//skip text till next token
+		j := bytes.IndexByte(in[i:], '<')
+		if j == -1 {
+			break
+		}
+		i += j
+
+		//get token type
+		ttype := getTokenType(in, i+1)
+
+		//TODO this should return token with all details
+		//get token endindex
+		var endIndex int
+		var inlineToken, xmlns bool
+		if ttype == startXMLToken {
+			endIndex, inlineToken, xmlns = scanStartTag(in, i+1)
+		} else {
+			endIndex, inlineToken = getTokenEndIndex(in, i+1, ttype)
+		}
 
-			//TODO this should return token with all details
-			//get token endindex
-			endIndex, inlineToken := getTokenEndIndex(in, i+1, ttype)
+		//invalid token
+		if endIndex == -1 {
+			return newSyntaxError(in, i, ttype, unterminatedReason(ttype))
+		}
 
-			//invalid token
-			if endIndex == -1 {
-				return errInvalidXML
+		if sp.opts.CharData && ttype != unknownXMLToken {
+			//unknown tokens are part of text
+			sp.addNode(in, text, i, textToken, ixpath, cb)
+			switch ttype {
+			case cdataXMLToken, commentsXMLToken, processingXMLToken:
+				sp.addNode(in, i, endIndex, ttype, ixpath, cb)
 			}
+			text = endIndex
+		}
 
-			if inlineToken {
-				ttype = endXMLToken
+		if sp.opts.Mode == StrictMode {
+			if err := sp.checkToken(in, i, endIndex, ttype, inlineToken, s.len()); err != nil {
+				return err
 			}
+		}
 
-			if ttype == startXMLToken {
-				//push start tag into stack and check only for endtags if those are matching to ours tag
-				token := XMLToken{
-					start: xmlTagIndex{si: i, ei: endIndex},
+		if inlineToken {
+			ttype = endXMLToken
+		}
+
+		if ttype == doctypeXMLToken {
+			if err := sp.parseDoctype(in, i, endIndex); err != nil {
+				return err
+			}
+		} else if ttype == startXMLToken {
+			//push start tag into stack and check only for endtags if those are matching to ours tag
+			token := XMLToken{
+				start: xmlTagIndex{si: i, ei: endIndex},
+			}
+			token.scope = sp.scope(in, &token, s.peek(), xmlns)
+
+			if sp.limits {
+				if err := sp.checkLimits(in, &token, s.len()+1); err != nil {
+					return err
 				}
-				s.push(Element{data: token, first: -1, last: -1, next: -1})
-			} else if ttype == endXMLToken {
-				//get start xml tag
-				var startTag *Element
-
-				if inlineToken {
-					startTag = &Element{
-						data: XMLToken{
-							start: xmlTagIndex{si: i, ei: endIndex},
-						},
-						first: -1, last: -1, next: -1,
-					}
-				} else {
-					startTag = s.pop()
+			}
+
+			//xpath handling
+			skip := false
+			if ixpath != nil {
+				skip = !sp.pushXPath(in, &token, s.len(), ixpath) && sp.opts.Mode == DefaultMode
+			}
+
+			s.push(Element{data: token, first: -1, last: -1, next: -1})
+
+			if skip {
+				//excluded subtree, continue with its end tag
+				end, err := sp.skipElement(in, endIndex, s.len())
+				if err != nil {
+					return err
+				}
+				if end != -1 {
+					i, text = end, end
+					continue
 				}
+			}
+		} else if ttype == endXMLToken {
+			//get start xml tag
+			var startTag *Element
 
-				if startTag == nil {
-					return errInvalidXML
+			if inlineToken {
+				startTag = &Element{
+					data: XMLToken{
+						start: xmlTagIndex{si: i, ei: endIndex},
+					},
+					first: -1, last: -1, next: -1,
 				}
-				startTag.data.end = xmlTagIndex{si: i, ei: endIndex}
+				startTag.data.scope = sp.scope(in, &startTag.data, s.peek(), xmlns)
 
-				if !inlineToken && !isValid(in, startTag) {
-					return errInvalidXML
+				if ixpath != nil {
+					sp.pushXPath(in, &startTag.data, s.len(), ixpath)
 				}
 
-				if cb != nil {
-					//append tokens to list
-					cb(string(startTag.data.Name(in[:])), s.peek(), *startTag)
+				if sp.limits {
+					if err := sp.checkLimits(in, &startTag.data, s.len()+1); err != nil {
+						return err
+					}
+				}
+			} else {
+				if sp.opts.Mode == LenientMode {
+					esi, eei := getTokenNameIndex(in, i+2)
+					open := s.len() - 1
+					for ; open >= 0 && !bytes.Equal(s.data[open].data.Name(in), in[esi:eei]); open-- {
+					}
+					if open == -1 {
+						sp.warn(in, i, endXMLToken, "ignored stray end tag </%s>", in[esi:eei])
+						i = endIndex
+						continue
+					}
+					//close intermediate elements at start of ancestor's end tag
+					for s.len() > open+1 {
+						child := s.pop()
+						child.data.end = xmlTagIndex{si: i, ei: i}
+						sp.warn(in, child.data.start.si, startXMLToken, "auto-closed <%s> at </%s>", child.data.Name(in), in[esi:eei])
+						sp.closeElement(in, s, xp, ixpath, cb, child)
+					}
 				}
+				startTag = s.pop()
+			}
+
+			if startTag == nil {
+				esi, eei := getTokenNameIndex(in, i+2)
+				return newSyntaxError(in, i, endXMLToken, "unexpected end tag </%s>", in[esi:eei])
+			}
+			startTag.data.end = xmlTagIndex{si: i, ei: endIndex}
 
-				//fmt.Printf("%s:<%d,%d,%d>\n", string(child.data.Name(in)), child.data.start.si, child.data.end.ei, child.data.end.ei-child.data.start.si)
+			if !inlineToken && !isValid(in, startTag) {
+				esi, eei := getTokenNameIndex(in, i+2)
+				return newSyntaxError(in, i, endXMLToken, "mismatched end tag </%s> for <%s>", in[esi:eei], startTag.data.Name(in))
+			}
+			if !inlineToken && sp.opts.Mode == StrictMode {
+				if err := checkEndTagQName(in, startTag.data.start.si, i); err != nil {
+					return err
+				}
 			}
-			i = endIndex
-			continue
+
+			sp.closeElement(in, s, xp, ixpath, cb, startTag)
+			//fmt.Printf("%s:<%d,%d,%d>\n", string(child.data.Name(in)), child.data.start.si, child.data.end.ei, child.data.end.ei-child.data.start.si)
 		}
-		i++
+		i = endIndex
+	}
+	if sp.opts.CharData {
+		sp.addNode(in, text, len(in), textToken, ixpath, cb)
+	}
+	for sp.opts.Mode == LenientMode && s.len() != 0 {
+		open := s.pop()
+		open.data.end = xmlTagIndex{si: len(in), ei: len(in)}
+		sp.warn(in, open.data.start.si, startXMLToken, "auto-closed unclosed element <%s> at EOF", open.data.Name(in))
+		sp.closeElement(in, s, xp, ixpath, cb, open)
 	}
 	if s.len() != 0 {
-		return errInvalidXML
+		open := s.peek()
+		return newSyntaxError(in, open.data.start.si, startXMLToken, "unclosed element <%s> at EOF", open.data.Name(in))
+	}
+	if sp.opts.Mode == StrictMode {
+		return sp.checkEOF(in)
 	}
 	return nil
 }
 
-func isValid(in []byte, node *Element) bool {
-	esi, eei := getTokenNameIndex(in, node.data.end.si+2)
-	return bytes.Equal(node.data.Name(in), in[esi:eei])
+/*
+closeElement reports closed element to callback, its parent is top of stack or
+in case of xpath the nearest ancestor matching any of the steps
+*/
+func (sp *XMLTokenizer) closeElement(in []byte, s *stack[Element], xp *stack[xpathFrame], ixpath *xpath, cb TokenHandler, startTag *Element) {
+	parent := s.peek()
+
+	//xpath handling
+	if ixpath != nil {
+		frame := xp.peek()
+		if frame == nil || frame.depth != s.len() {
+			return //no rule is active for element
+		}
+		xp.pop()
+		sp.states = sp.states[:frame.si]
+		if !frame.matched {
+			return
+		}
+		parent = nil
+		for i := xp.len() - 1; i >= 0; i-- {
+			if xp.data[i].matched {
+				parent = &s.data[xp.data[i].depth]
+				break
+			}
+		}
+	}
+
+	if cb != nil {
+		//append tokens to list, name is cached in token before it is copied
+		name := sp.name(startTag.data.Name(in))
+		cb(name, parent, *startTag)
+	}
 }
 
-func (sp *XMLTokenizer) ParseWithXPath(in []byte, ixpath *xpath, cb TokenHandler) error {
-	/*
-		TODO:
-		1. get s from pool,
-		2. get xp from pool, iff xp.path present
-		3. adding defer to put stack and xp into pool back
-	*/
-	var s stack[Element]
-	var xp stack[*xpath]
+// pushXPath evaluates rule steps of parent element against element token starting at depth, returns false if no rule is active for element
+func (sp *XMLTokenizer) pushXPath(in []byte, token *XMLToken, depth int, ixpath *xpath) bool {
+	var parent []xpathState
+	if depth == 0 {
+		parent = []xpathState{{node: ixpath}}
+	} else if frame := sp.xp.peek(); frame != nil && frame.depth == depth-1 {
+		parent = sp.states[frame.si:frame.ei]
+	} else {
+		return false //parent is not part of any rule
+	}
 
-	for i := 0; i < len(in); {
-		if in[i] == '<' {
-			//get token type
-			ttype := getTokenType(in, i+1)
+	si, matched := len(sp.states), false
+	for _, p := range parent {
+		if !p.descendantOnly {
+			if c := p.node.childs[string(token.Name(in))]; c != nil {
+				sp.addState(si, c, false)
+				matched = true
+			}
+		}
+		for _, c := range p.node.steps {
+			if (!p.descendantOnly || c.descendant) && c.match(in, token) {
+				sp.addState(si, c, false)
+				matched = true
+			}
+		}
+		if p.node.deep {
+			//keep waiting for descendant steps, other steps match only direct childs
+			sp.addState(si, p.node, true)
+		}
+	}
+	if len(sp.states) == si {
+		return false
+	}
+	sp.xp.push(xpathFrame{depth: depth, si: si, ei: len(sp.states), matched: matched})
+	return true
+}
 
-			//TODO this should return token with all details
-			//get token endindex
-			endIndex, inlineToken := getTokenEndIndex(in, i+1, ttype)
+/*
+skipElement returns index of end tag closing element at depth whose content starts at index, -1 if not found,
+nested tags are only counted, their names are not validated, limits are checked for nested elements
+*/
+func (sp *XMLTokenizer) skipElement(in []byte, index, depth int) (int, error) {
+	nested := 1
+	for i := index; ; {
+		j := bytes.IndexByte(in[i:], '<')
+		if j == -1 || i+j+1 >= len(in) {
+			return -1, nil
+		}
+		i += j
 
-			//invalid token
-			if endIndex == -1 {
-				return errInvalidXML
+		switch in[i+1] {
+		case '/':
+			if nested--; nested == 0 {
+				return i, nil
 			}
-
-			if inlineToken {
-				ttype = endXMLToken
+			j = bytes.IndexByte(in[i:], '>')
+			if j == -1 {
+				return -1, nil
+			}
+			i += j + 1
+		default:
+			//comments, CDATA and processing instructions may contain tags
+			ttype := getTokenType(in, i+1)
+			end, inline := getTokenEndIndex(in, i+1, ttype)
+			if end == -1 {
+				return -1, nil
 			}
-
 			if ttype == startXMLToken {
-				//push start tag into stack and check only for endtags if those are matching to ours tag
-				token := XMLToken{
-					start: xmlTagIndex{si: i, ei: endIndex},
-				}
-
-				//xpath handling
-				if ixpath != nil && s.len() == xp.len() {
-					path := xp.peek()
-					if path == nil {
-						path = &ixpath
-					}
-
-					/*NOTE: do not use existing path, it will update stack variable*/
-					p := (*path).get(string(token.Name(in)))
-					if p != nil {
-						xp.push(p)
+				if sp.limits {
+					token := XMLToken{start: xmlTagIndex{si: i, ei: end}}
+					if err := sp.checkLimits(in, &token, depth+nested); err != nil {
+						return -1, err
 					}
 				}
-
-				s.push(Element{data: token, first: -1, last: -1, next: -1})
-			} else if ttype == endXMLToken {
-				//get start xml tag
-				foundTag := true
-				var startTag *Element
-
-				if inlineToken {
-					startTag = &Element{
-						data: XMLToken{
-							start: xmlTagIndex{si: i, ei: endIndex},
-						},
-						first: -1, last: -1, next: -1,
-					}
-				} else {
-					startTag = s.pop()
-				}
-
-				if startTag == nil {
-					return errInvalidXML
-				}
-				startTag.data.end = xmlTagIndex{si: i, ei: endIndex}
-
-				if !inlineToken && !isValid(in, startTag) {
-					return errInvalidXML
+				if !inline {
+					nested++
 				}
+			}
+			i = end
+		}
+	}
+}
 
-				//xpath handling
-				if ixpath != nil {
-					if s.len() < xp.len() {
-						xp.pop()
-					} else {
-						foundTag = false
-					}
-				}
+/*
+addNode reports text, CDATA, comment or processing instruction in[si:ei] as child node of open element,
+comments and processing instructions outside root element are reported without parent, xml declaration is not reported
+*/
+func (sp *XMLTokenizer) addNode(in []byte, si, ei int, kind xmlTokenType, ixpath *xpath, cb TokenHandler) {
+	parent := sp.s.peek()
+	if si >= ei || cb == nil {
+		return
+	}
+	if parent == nil && (ixpath != nil || kind == textToken || kind == cdataXMLToken) {
+		return
+	}
+	if ixpath != nil {
+		if frame := sp.xp.peek(); frame == nil || frame.depth != sp.s.len()-1 || !frame.matched {
+			return //parent element is not reported
+		}
+	}
+	node := Element{
+		data: XMLToken{
+			start: xmlTagIndex{si: si, ei: ei},
+			end:   xmlTagIndex{si: ei, ei: ei},
+			kind:  kind,
+		},
+		first: -1, last: -1, next: -1,
+	}
+	switch kind {
+	case cdataXMLToken:
+		cb("#cdata-section", parent, node)
+	case commentsXMLToken:
+		cb("#comment", parent, node)
+	case processingXMLToken:
+		target := node.data.Name(in)
+		if string(target) == "xml" {
+			return //declaration, see XMLReader.Declaration
+		}
+		cb(string(target), parent, node)
+	default:
+		cb("#text", parent, node)
+	}
+}
 
-				if foundTag && cb != nil {
-					//append tokens to list
-					cb(string(startTag.data.Name(in[:])), s.peek(), *startTag)
-				}
-				//fmt.Printf("%s:<%d,%d,%d>\n", string(child.data.Name(in)), child.data.start.si, child.data.end.ei, child.data.end.ei-child.data.start.si)
-			}
-			i = endIndex
-			continue
+func (sp *XMLTokenizer) addState(si int, p *xpath, descendantOnly bool) {
+	for i := si; i < len(sp.states); i++ {
+		if state := &sp.states[i]; state.node == p {
+			state.descendantOnly = state.descendantOnly && descendantOnly
+			return
 		}
-		i++
 	}
-	if s.len() != 0 {
-		return errInvalidXML
+	sp.states = append(sp.states, xpathState{node: p, descendantOnly: descendantOnly})
+}
+
+// name returns element name as string, repeated names don't allocate
+func (sp *XMLTokenizer) name(b []byte) string {
+	if len(b) == 0 || len(b) > maxCachedNameLen {
+		return string(b)
 	}
-	return nil
+	if sp.names == nil {
+		sp.names = new([cachedNames]string)
+	}
+	slot := &sp.names[(len(b)*31+int(b[0])*7+int(b[len(b)-1]))%cachedNames]
+	if *slot != string(b) {
+		*slot = string(b)
+	}
+	return *slot
+}
+
+func isValid(in []byte, node *Element) bool {
+	name := node.data.Name(in)
+	//same qualified name in end tag, local names are equal
+	qname := in[node.data.start.si+1 : node.data.name.ei]
+	if i := node.data.end.si + 2 + len(qname); i < node.data.end.ei && bytes.HasPrefix(in[node.data.end.si+2:], qname) && (in[i] == '>' || whitespace[in[i]]) {
+		return true
+	}
+	esi, eei := getTokenNameIndex(in, node.data.end.si+2)
+	return bytes.Equal(name, in[esi:eei])
 }
 
 /*
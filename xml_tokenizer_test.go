@@ -3,6 +3,7 @@ package fastxml
 import (
 	"bytes"
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -157,6 +158,13 @@ func TestXMLTokenizerParse(t *testing.T) {
 			expected:   2,
 			tokenNames: []string{"child", "root"},
 		},
+		{
+			name:       "unicode_names",
+			input:      `<Каталог><Цена валюта="₽">10</Цена><été/><_a.b/></Каталог>`,
+			wantErr:    false,
+			expected:   4,
+			tokenNames: []string{"Цена", "été", "_a.b", "Каталог"},
+		},
 		// Invalid XML cases
 		{
 			name:       "missing_end_tag",
@@ -210,7 +218,7 @@ func TestXMLTokenizerParse(t *testing.T) {
 
 			if tt.wantErr {
 				assert.Error(t, err)
-				assert.Equal(t, errInvalidXML, err)
+				assert.ErrorIs(t, err, ErrInvalidXML)
 			} else {
 				assert.NoError(t, err)
 				if handler != nil {
@@ -234,3 +242,109 @@ func TestXMLTokenizer(t *testing.T) {
 	err := parser.Parse([]byte(xml), nil)
 	assert.NoError(t, err)
 }
+
+func TestXMLTokenizerNames(t *testing.T) {
+	parser := NewXMLTokenizer()
+	names := []string{}
+	err := parser.Parse([]byte(`<root><a/><a></a><b/></root>`), func(name string, _ *Element, _ Element) {
+		names = append(names, name)
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "a", "b", "root"}, names)
+	name := []byte("a")
+	assert.Zero(t, testing.AllocsPerRun(10, func() { parser.name(name) }))
+
+	//cache doesn't grow, long names are not cached
+	for i := 0; i < 2*cachedNames; i++ {
+		assert.Equal(t, fmt.Sprintf("e%d", i), parser.name([]byte(fmt.Sprintf("e%d", i))))
+	}
+	long := strings.Repeat("n", maxCachedNameLen+1)
+	assert.Equal(t, long, parser.name([]byte(long)))
+	assert.NotContains(t, parser.names[:], long)
+	assert.Equal(t, "", parser.name(nil))
+}
+
+func Test_isValid(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want bool
+	}{
+		{name: `same_name`, in: `<a></a>`, want: true},
+		{name: `whitespace_in_end_tag`, in: `<a k="v"></a ` + "\n" + `>`, want: true},
+		{name: `same_qualified_name`, in: `<x:a></x:a>`, want: true},
+		{name: `different_prefix`, in: `<x:a></y:a>`, want: true},
+		{name: `missing_prefix`, in: `<x:a></a>`, want: true},
+		{name: `name_prefix`, in: `<a></ab>`, want: false},
+		{name: `different_name`, in: `<ab></a>`, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := []byte(tt.in)
+			gt := bytes.IndexByte(in, '>') + 1
+			node := &Element{data: NewXMLToken(0, gt, bytes.LastIndexByte(in, '<'), len(in))}
+			assert.Equal(t, tt.want, isValid(in, node))
+		})
+	}
+}
+
+func Test_skipElement(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want int
+	}{
+		{name: "empty", in: `<a></a>`, want: 3},
+		{name: "text", in: `<a>text</a>`, want: 7},
+		{name: "nested", in: `<a><a><b/></a></a>`, want: 14},
+		{name: "cdata_comment_pi", in: `<a><![CDATA[</a>]]><!--</a>--><?pi </a>?></a>`, want: 41},
+		{name: "quoted_gt", in: `<a><b k="/>"></b></a>`, want: 17},
+		{name: "lt_in_text", in: `<a>1 < 2 ></a>`, want: 10},
+		{name: "not_closed", in: `<a><b></b>`, want: -1},
+		{name: "unterminated_tag", in: `<a><b`, want: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			end, err := NewXMLTokenizer().skipElement([]byte(tt.in), 3, 1)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, end)
+		})
+	}
+}
+
+func TestXMLTokenizerSkipExcludedLimits(t *testing.T) {
+	in := []byte(`<VAST><Ad><Impression>i</Impression></Ad><Extensions><Extension><Data a="1" b="2"><x/><LongElementName/></Data></Extension><Extension/></Extensions></VAST>`)
+	rule := GetXPath([][]string{{"VAST", "Ad", "Impression"}})
+	for _, opts := range []ParseOptions{{MaxDepth: 4}, {MaxElements: 8}, {MaxAttributes: 1}, {MaxNameLength: 10}} {
+		//excluded subtree is skipped, limits report same error as full parse
+		want := NewXMLTokenizerWithOptions(opts).Parse(in, nil)
+		got := NewXMLTokenizerWithOptions(opts).ParseWithXPath(in, rule, nil)
+		var lerr *LimitError
+		if assert.ErrorAs(t, got, &lerr, "%+v", opts) {
+			assert.Equal(t, want, got)
+		}
+	}
+	assert.NoError(t, NewXMLTokenizerWithOptions(ParseOptions{MaxDepth: 5, MaxElements: 9, MaxAttributes: 2, MaxNameLength: 15}).ParseWithXPath(in, rule, nil))
+}
+
+func TestXMLTokenizerSkipExcluded(t *testing.T) {
+	in := []byte(`<VAST><Ad><InLine><Impression>i</Impression><Extensions><Extension type="a>b"><![CDATA[</Extensions>]]><x><y/></x></Extension><!-- </Extensions> --></Extensions></InLine></Ad></VAST>`)
+	rule := GetXPath([][]string{{"VAST", "Ad", "InLine", "Impression"}})
+
+	var skipped, full []string
+	cb := func(out *[]string) TokenHandler {
+		return func(name string, parent *Element, child Element) {
+			*out = append(*out, fmt.Sprintf("%s:%d:%d", name, child.data.start.si, child.data.end.ei))
+		}
+	}
+	assert.NoError(t, NewXMLTokenizer().ParseWithXPath(in, rule, cb(&skipped)))
+	assert.NoError(t, NewXMLTokenizerWithOptions(ParseOptions{Mode: StrictMode}).ParseWithXPath(in, rule, cb(&full)))
+	assert.Equal(t, full, skipped)
+	assert.Equal(t, []string{"Impression:18:44", "InLine:10:170", "Ad:6:175", "VAST:0:182"}, skipped)
+
+	//names inside excluded subtree are not validated
+	in = []byte(`<VAST><Ad><Impression>i</Impression></Ad><Extensions><x></y></Extensions></VAST>`)
+	rule = GetXPath([][]string{{"VAST", "Ad", "Impression"}})
+	assert.NoError(t, NewXMLTokenizer().ParseWithXPath(in, rule, nil))
+	assert.ErrorIs(t, NewXMLTokenizer().Parse(in, nil), ErrInvalidXML)
+}
@@ -2,6 +2,7 @@ package fastxml
 
 import (
 	"bytes"
+	"unicode/utf8"
 )
 
 type XMLEscapingMode int
@@ -12,7 +13,7 @@ const (
 	XMLUnescapeMode
 )
 
-type xmlTokenType int
+type xmlTokenType uint8
 
 const (
 	unknownXMLToken    xmlTokenType = iota //unknown token
@@ -54,7 +55,9 @@ type xmlTagIndex struct {
 type XMLToken struct {
 	start, end xmlTagIndex
 	name, text xmlTagIndex
+	scope      int //namespace scope, see XMLTokenizer.scope
 	cdata      bool
+	kind       xmlTokenType //textToken, cdataXMLToken, commentsXMLToken or processingXMLToken for non element nodes, zero for elements
 }
 
 func NewXMLToken(ssi, sei, esi, eei int) XMLToken {
@@ -69,6 +72,27 @@ func (t *XMLToken) XMLTag(in []byte) []byte {
 }
 
 func (t *XMLToken) Text(in []byte) []byte {
+	switch t.kind {
+	case textToken:
+		return in[t.start.si:t.start.ei]
+	case cdataXMLToken:
+		return in[t.start.si+len(cdataStart) : t.start.ei-len(cdataEnd)]
+	case commentsXMLToken:
+		if t.start.si+4 > t.start.ei-3 {
+			return nil
+		}
+		return in[t.start.si+4 : t.start.ei-3]
+	case processingXMLToken:
+		//instruction data following target
+		si := t.start.si + 2 + len(t.Name(in))
+		for si < t.start.ei-2 && whitespace[in[si]] {
+			si++
+		}
+		if si > t.start.ei-2 {
+			return nil
+		}
+		return in[si : t.start.ei-2]
+	}
 	if t.start.si == t.end.si {
 		return nil //inline tag doesn't have text
 	}
@@ -79,6 +103,12 @@ func (t *XMLToken) Text(in []byte) []byte {
 }
 
 func (t *XMLToken) Name(in []byte) []byte {
+	if t.kind == processingXMLToken {
+		return in[t.start.si+2 : piTargetEnd(in, t.start.si+2, t.start.ei-2)]
+	}
+	if t.kind != 0 {
+		return nil //character data or comment node
+	}
 	if t.name.si == 0 {
 		t.name.si, t.name.ei = getTokenNameIndex(in, t.start.si+1)
 	}
@@ -92,16 +122,47 @@ func (t *XMLToken) NSName(in []byte) []byte {
 	return in[t.start.si+1 : t.name.ei]
 }
 
+// Prefix returns namespace prefix of element name, empty if not prefixed
+func (t *XMLToken) Prefix(in []byte) []byte {
+	if t.name.si == 0 {
+		t.name.si, t.name.ei = getTokenNameIndex(in, t.start.si+1)
+	}
+	if t.name.si > t.start.si+1 {
+		return in[t.start.si+1 : t.name.si-1]
+	}
+	return nil
+}
+
 func (t XMLToken) ParseAttribute(in []byte) []Attribute {
 	offset := 1
-	if t.start.si == t.end.si {
+	if t.IsInline() {
 		offset = 2 //check for inline token eg: <test k="v"/>
 	}
 	return parseAttributes(in[:], t.name.ei, t.start.ei-offset)
 }
 
+// IsElement reports whether token is an element, not a character data node
+func (t XMLToken) IsElement() bool {
+	return t.kind == 0
+}
+
+// IsText reports whether token is a text or CDATA node
+func (t XMLToken) IsText() bool {
+	return t.kind == textToken || t.kind == cdataXMLToken
+}
+
+// IsComment reports whether token is a comment node
+func (t XMLToken) IsComment() bool {
+	return t.kind == commentsXMLToken
+}
+
+// IsProcInst reports whether token is a processing instruction node
+func (t XMLToken) IsProcInst() bool {
+	return t.kind == processingXMLToken
+}
+
 func (t XMLToken) IsInline() bool {
-	return (t.start.ei == t.end.ei)
+	return (t.start == t.end)
 }
 
 func (t XMLToken) StartTagOffset() (si, ei int) {
@@ -117,6 +178,9 @@ func (t XMLToken) TagOffset() (si, ei int) {
 }
 
 func (t XMLToken) IsCDATA(in []byte) bool {
+	if t.kind != 0 {
+		return t.kind == cdataXMLToken
+	}
 	if t.start.si == t.end.si {
 		return false //inline tag doesn't have text
 	}
@@ -164,7 +228,7 @@ func getTokenType(in []byte, index int) xmlTokenType {
 	case '?':
 		return processingXMLToken
 	default:
-		if alpha[ch] {
+		if isNameStart(in, index) {
 			return startXMLToken
 		}
 	}
@@ -178,8 +242,14 @@ func getTokenNameIndex(in []byte, startIndex int) (si, ei int) {
 		if name[in[i]] {
 			continue
 		}
+		if in[i] >= utf8.RuneSelf {
+			if size := nameRuneLen(in, i, false); size > 0 {
+				i += size - 1
+				continue
+			}
+		}
 		if in[i] == '>' || whitespace[in[i]] || in[i] == '/' {
-			if alpha[in[si]] || in[si] == '_' {
+			if isNameStart(in, si) {
 				return si, i
 			}
 		} else if in[i] == ':' && !firstNameSpace {
@@ -193,100 +263,137 @@ func getTokenNameIndex(in []byte, startIndex int) (si, ei int) {
 	return startIndex, startIndex //not found
 }
 
+// piTargetEnd returns end index of processing instruction target starting at si, ei is start of closing ?>
+func piTargetEnd(in []byte, si, ei int) int {
+	for si < ei && !whitespace[in[si]] {
+		si++
+	}
+	return si
+}
+
+/*
+writeMixedText writes character data of element content in[si:ei] to u, text is unescaped and CDATA copied,
+comments, processing instructions and unless deep is set content of child elements are skipped,
+cdata reports whether any CDATA was found, index of returned error is offset in input
+*/
+func writeMixedText(u *unescaper, in []byte, si, ei int, deep bool) (cdata bool, err error) {
+	depth := 0
+	for i := si; i < ei; {
+		end := ei
+		if j := bytes.IndexByte(in[i:ei], '<'); j != -1 {
+			end = i + j
+		}
+		if (depth == 0 || deep) && end > i {
+			if err := u.unescape(in[i:end], 0); err != nil {
+				err.(*unescapeError).index += i
+				return cdata, err
+			}
+		}
+		if end == ei {
+			break
+		}
+
+		i = end
+		ttype := getTokenType(in, i+1)
+		tend, inline := getTokenEndIndex(in[:ei], i+1, ttype)
+		if tend == -1 {
+			tend = ei //malformed content, rest is skipped
+		}
+		switch ttype {
+		case startXMLToken:
+			if !inline {
+				depth++
+			}
+		case endXMLToken:
+			depth--
+		case cdataXMLToken:
+			if depth == 0 || deep {
+				u.w.Write(in[i+len(cdataStart) : tend-len(cdataEnd)])
+				cdata = true
+			}
+		case unknownXMLToken:
+			//part of text
+			if depth == 0 || deep {
+				if err := u.unescape(in[i:tend], 0); err != nil {
+					err.(*unescapeError).index += i
+					return cdata, err
+				}
+			}
+		}
+		i = tend
+	}
+	return cdata, nil
+}
+
+// scanStartTag returns index after start tag whose name starts at in[startIndex], -1 if not terminated, xmlns reports whether any attribute name starts with xmlns
+func scanStartTag(in []byte, startIndex int) (index int, inline, xmlns bool) {
+	// read until > which is not part of quoted attribute value, single forward pass
+	for i := startIndex; i < len(in); i++ {
+		switch in[i] {
+		case '>':
+			return i + 1, i > 0 && in[i-1] == '/', xmlns
+		case '"', '\'':
+			//skip quoted attribute value, it may contain >
+			closing := bytes.IndexByte(in[i+1:], in[i])
+			if closing == -1 {
+				return -1, false, xmlns
+			}
+			i += 1 + closing
+		case 'x':
+			//attribute names follow whitespace, element name follows <
+			if !xmlns && i > startIndex && whitespace[in[i-1]] && bytes.HasPrefix(in[i:], xmlnsPrefix) {
+				xmlns = true
+			}
+		}
+	}
+	return -1, false, xmlns
+}
+
 func getTokenEndIndex(in []byte, startIndex int, ttype xmlTokenType) (int, bool) {
 	index := -1
 	inline := false
 	//TODO: write token type based parsers and execute it separately
 	switch ttype {
 	case startXMLToken:
-		// read until >
-		for i := startIndex; i < len(in); i++ {
-			if in[i] == '>' {
-				if i > 0 && in[i-1] == '/' {
-					inline = true
-				}
-				//found end tag
-				index = i + 1
-				break
-			}
-		}
+		index, inline, _ = scanStartTag(in, startIndex)
 	case endXMLToken:
 		// read until >
-		for i := startIndex; i < len(in); i++ {
-			if in[i] == '>' {
-				//found end tag
-				index = i + 1
-				break
-			}
+		if end := bytes.IndexByte(in[startIndex:], '>'); end != -1 {
+			index = startIndex + end + 1
 		}
 	case processingXMLToken:
-		// read until ?>
-		for i := startIndex; i < len(in); i++ {
-			if in[i] == '>' && i > 0 && in[i-1] == '?' {
-				//found end tag
-				index = i + 1
-				break
+		// read until ?> past <?
+		if si := startIndex + 1; si <= len(in) {
+			if end := bytes.Index(in[si:], []byte("?>")); end != -1 {
+				index = si + end + 2
 			}
 		}
 	case commentsXMLToken:
-		// read until found -->
-		for i := startIndex; i < len(in); i++ {
-			if in[i] == '>' && i > 1 && in[i-1] == '-' && in[i-2] == '-' {
-				//found end tag
-				index = i + 1
-				break
+		// read until found --> past <!--
+		if si := startIndex + 3; si <= len(in) {
+			if end := bytes.Index(in[si:], []byte("-->")); end != -1 {
+				index = si + end + 3
 			}
 		}
 	case cdataXMLToken:
 		// read until ]]> /*<![CDATA[ 25.00 ]]>*/
-		for i := startIndex; i < len(in); i++ {
-			if in[i] == '>' && i > 1 && in[i-1] == ']' && in[i-2] == ']' {
-				/*
-					TODO: Special handling (https://en.wikipedia.org/wiki/CDATA#Nesting)
-					input: <![CDATA[ data ]]> data ]]>
-					replace ]]> with ]]]]><![CDATA[>
-					output: <![CDATA[ data ]]]]><![CDATA[> data ]]>
-					action: ignore if found ']]]]><![CDATA[>'
-				*/
-				//found end tag
-				index = i + 1
-				break
-			}
-		}
-	case doctypeXMLToken:
 		/*
-			TODO:
-			1. UNDER DEVELOPMENT
-			2. Nested DCOTYPE strict checking not supported yet
-			if DOCTYPE contains [ start then ends with ]> else ends with >
+			TODO: Special handling (https://en.wikipedia.org/wiki/CDATA#Nesting)
+			input: <![CDATA[ data ]]> data ]]>
+			replace ]]> with ]]]]><![CDATA[>
+			output: <![CDATA[ data ]]]]><![CDATA[> data ]]>
+			action: ignore if found ']]]]><![CDATA[>'
 		*/
-		var bracketCounts int
-		for i := startIndex; i < len(in); i++ {
-			if in[i] == '>' {
-				if bracketCounts == 0 {
-					//found end tag
-					index = i + 1
-				} else {
-					if bracketCounts == 1 && i > 0 && in[i-1] == ']' {
-						index = i + 1
-					} else {
-						bracketCounts--
-						continue
-					}
-				}
-				break
-			} else if in[i] == '[' {
-				//TODO: Buggy no strict checking of tag
-				bracketCounts++
-			}
+		if end := bytes.Index(in[startIndex:], cdataEnd); end != -1 {
+			index = startIndex + end + 3
 		}
+	case doctypeXMLToken:
+		// read until > which is not part of quoted literal or internal subset
+		index = doctypeEndIndex(in, startIndex)
 	default:
 		//read token based on tokentype
-		for i := startIndex; i < len(in); i++ {
-			if in[i] == '>' {
-				index = i + 1
-				break
-			}
+		if end := bytes.IndexByte(in[startIndex:], '>'); end != -1 {
+			index = startIndex + end + 1
 		}
 	}
 	return index, inline
@@ -65,6 +65,8 @@ func Test_getTokenType(t *testing.T) {
 				{in: ``, index: 0},
 				{in: `<test/>`, index: 10},
 				{in: `<123test/>`, index: 1},
+				{in: `<.test/>`, index: 1},
+				{in: `<×test/>`, index: 1},
 				{in: `< test/>`, index: 1},
 				{in: `<! -- test -->`, index: 1},
 				{in: `<! [CDATA[test]]>`, index: 1},
@@ -132,6 +134,36 @@ func Test_getTokenEndIndex(t *testing.T) {
 			args: args{in: `/>`, ttype: startXMLToken},
 			want: want{index: 2, inline: true},
 		},
+		{
+			name: `start_token_gt_in_double_quoted_value`,
+			args: args{in: `<Tracking event="x" offset=">">url</Tracking>`, ttype: startXMLToken},
+			want: want{index: 31, inline: false},
+		},
+		{
+			name: `start_token_gt_in_single_quoted_value`,
+			args: args{in: `<a href='http://x.com/?a>b' k="'"/>text`, ttype: startXMLToken},
+			want: want{index: 35, inline: true},
+		},
+		{
+			name: `start_token_gt_in_mixed_quoted_values`,
+			args: args{in: `<a x="1" y='>' z="'>'">text`, ttype: startXMLToken},
+			want: want{index: 23, inline: false},
+		},
+		{
+			name: `start_token_slash_in_quoted_value`,
+			args: args{in: `<a x="/">text`, ttype: startXMLToken},
+			want: want{index: 9, inline: false},
+		},
+		{
+			name: `start_token_many_attributes`,
+			args: args{in: `<a a0="0" a1='1' a2="2>" a3='3' a4="4"/>`, ttype: startXMLToken},
+			want: want{index: 40, inline: true},
+		},
+		{
+			name: `start_token_unterminated_quote`,
+			args: args{in: `<a href="http://x.com/>text</a>`, ttype: startXMLToken},
+			want: want{index: -1, inline: false},
+		},
 		{
 			name: `end_token_empty`,
 			args: args{in: ``, ttype: endXMLToken},
@@ -158,9 +190,14 @@ func Test_getTokenEndIndex(t *testing.T) {
 			want: want{index: -1, inline: false},
 		},
 		{
-			name: `preprocessing_token_first_char`,
-			args: args{in: `?>`, ttype: processingXMLToken},
-			want: want{index: 2, inline: false},
+			name: `preprocessing_token_empty_instruction`,
+			args: args{in: `<??>`, startIndex: 1, ttype: processingXMLToken},
+			want: want{index: 4, inline: false},
+		},
+		{
+			name: `preprocessing_token_closer_overlaps_opener`,
+			args: args{in: `<?>`, startIndex: 1, ttype: processingXMLToken},
+			want: want{index: -1, inline: false},
 		},
 		{
 			name: `preprocessing_token_valid`,
@@ -183,9 +220,19 @@ func Test_getTokenEndIndex(t *testing.T) {
 			want: want{index: -1, inline: false},
 		},
 		{
-			name: `comments_token_first_char`,
-			args: args{in: `-->`, ttype: commentsXMLToken},
-			want: want{index: 3, inline: false},
+			name: `comments_token_empty_comment`,
+			args: args{in: `<!---->`, startIndex: 1, ttype: commentsXMLToken},
+			want: want{index: 7, inline: false},
+		},
+		{
+			name: `comments_token_closer_overlaps_opener`,
+			args: args{in: `<!-->`, startIndex: 1, ttype: commentsXMLToken},
+			want: want{index: -1, inline: false},
+		},
+		{
+			name: `comments_token_closer_overlaps_opener_dash`,
+			args: args{in: `<!--->`, startIndex: 1, ttype: commentsXMLToken},
+			want: want{index: -1, inline: false},
 		},
 		{
 			name: `comments_token_first_char_missing_dashes`,
@@ -298,6 +345,72 @@ func Test_getTokenEndIndex(t *testing.T) {
 	}
 }
 
+func Test_scanStartTag(t *testing.T) {
+	tests := []struct {
+		name   string
+		in     string
+		index  int
+		inline bool
+		xmlns  bool
+	}{
+		{name: `no_attributes`, in: `<a>`, index: 3},
+		{name: `inline`, in: `<a k="v"/>`, index: 10, inline: true},
+		{name: `default_namespace`, in: `<a xmlns="u">`, index: 13, xmlns: true},
+		{name: `prefixed_namespace_after_attribute`, in: `<a k="v"` + "\n\t" + `xmlns:p="u"/>`, index: 23, inline: true, xmlns: true},
+		{name: `xmlns_in_value`, in: `<a k=" xmlns='u'">`, index: 18},
+		{name: `xmlns_in_element_name`, in: `<xmlns k="v">`, index: 13},
+		{name: `xmlns_in_attribute_name`, in: `<a axmlns="u">`, index: 14},
+		{name: `unterminated`, in: `<a xmlns="u>`, index: -1, xmlns: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			index, inline, xmlns := scanStartTag([]byte(tt.in), 1)
+			assert.Equal(t, tt.index, index)
+			assert.Equal(t, tt.inline, inline)
+			assert.Equal(t, tt.xmlns, xmlns)
+		})
+	}
+}
+
+func TestXMLTokenText(t *testing.T) {
+	tests := []struct {
+		name  string
+		in    string
+		token XMLToken
+		want  []byte
+	}{
+		{
+			name:  `comment`,
+			in:    `<!--x-->`,
+			token: XMLToken{start: xmlTagIndex{si: 0, ei: 8}, kind: commentsXMLToken},
+			want:  []byte(`x`),
+		},
+		{
+			name:  `comment_closer_overlaps_opener`,
+			in:    `<!-->`,
+			token: XMLToken{start: xmlTagIndex{si: 0, ei: 5}, kind: commentsXMLToken},
+			want:  nil,
+		},
+		{
+			name:  `processing_instruction`,
+			in:    `<?p i?>`,
+			token: XMLToken{start: xmlTagIndex{si: 0, ei: 7}, kind: processingXMLToken},
+			want:  []byte(`i`),
+		},
+		{
+			name:  `processing_instruction_closer_overlaps_opener`,
+			in:    `<?>`,
+			token: XMLToken{start: xmlTagIndex{si: 0, ei: 3}, kind: processingXMLToken},
+			want:  nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.token.Text([]byte(tt.in)))
+		})
+	}
+}
+
 func Test_getTokenNameIndex(t *testing.T) {
 	type args struct {
 		in         string
@@ -339,6 +452,36 @@ func Test_getTokenNameIndex(t *testing.T) {
 			args: args{in: `<xn1:xn2:test/>`, startIndex: 1},
 			want: ``,
 		},
+		{
+			name: `dot_in_name`,
+			args: args{in: `<ad.server-id>`, startIndex: 1},
+			want: `ad.server-id`,
+		},
+		{
+			name: `unicode_name`,
+			args: args{in: `<été k="v">`, startIndex: 1},
+			want: `été`,
+		},
+		{
+			name: `unicode_namespace_name`,
+			args: args{in: `<ns:Цена/>`, startIndex: 1},
+			want: `Цена`,
+		},
+		{
+			name: `unicode_combining_char`,
+			args: args{in: "<a\u0301\u00b7b>", startIndex: 1},
+			want: "a\u0301\u00b7b",
+		},
+		{
+			name: `invalid_unicode_start_char`,
+			args: args{in: "<\u0301a>", startIndex: 1},
+			want: ``,
+		},
+		{
+			name: `invalid_unicode_char`,
+			args: args{in: `<a×b>`, startIndex: 1},
+			want: ``,
+		},
 		{
 			name: `invalid_special_character_start`,
 			args: args{in: `<#test/>`, startIndex: 1},
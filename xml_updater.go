@@ -194,8 +194,12 @@ func (xu *XMLUpdater) RemoveAttribute(attr *Attribute) {
 	if attr == nil {
 		return
 	}
+	si := attr.key.si - 1
+	if prefix := attr.Prefix(xu.xmlReader.in); len(prefix) > 0 {
+		si -= len(prefix) + 1 //remove namespace along with key
+	}
 	xu.ops = append(xu.ops, xmlOperation{
-		si: attr.key.si - 1,
+		si: si,
 		ei: attr.value.ei + 1,
 	})
 }
@@ -260,6 +264,35 @@ func (xu *XMLUpdater) UpdateAttributeName(attr *Attribute, key string) {
 }
 */
 
+// SetDeclaration adds or replaces xml declaration of document, empty encoding and standalone are omitted
+func (xu *XMLUpdater) SetDeclaration(version, encoding, standalone string) {
+	si, ei := 0, 0
+	if decl := xu.xmlReader.Declaration(); decl != nil {
+		si, ei = decl.Start, decl.End
+	}
+	xu.ops = append(xu.ops, xmlOperation{
+		si:   si,
+		ei:   ei,
+		data: NewXMLText(declarationXML(version, encoding, standalone), false, NoEscaping),
+	})
+}
+
+// RemoveDeclaration removes xml declaration of document along with whitespaces following it
+func (xu *XMLUpdater) RemoveDeclaration() {
+	decl := xu.xmlReader.Declaration()
+	if decl == nil {
+		return
+	}
+	in, ei := xu.xmlReader.in, decl.End
+	for ei < len(in) && whitespace[in[ei]] {
+		ei++
+	}
+	xu.ops = append(xu.ops, xmlOperation{
+		si: decl.Start,
+		ei: ei,
+	})
+}
+
 func (xu *XMLUpdater) applyElementSettings(element *Element) {
 	if !element.IsLeaf() {
 		return
@@ -1144,3 +1144,70 @@ func TestXMLUpdater_ApplyXMLSettingsOperations(t *testing.T) {
 		})
 	}
 }
+
+func TestXMLUpdaterQuotedGreaterThan(t *testing.T) {
+	xmlDoc := []byte(`<TrackingEvents><Tracking event="x" offset=">"><![CDATA[http://t.com/?a>b]]></Tracking><Tracking event='y>'/></TrackingEvents>`)
+
+	reader := NewXMLReader()
+	if !assert.NoError(t, reader.Parse(xmlDoc)) {
+		return
+	}
+
+	trackings := reader.SelectElements(nil, "TrackingEvents", "Tracking")
+	if !assert.Len(t, trackings, 2) {
+		return
+	}
+	assert.Equal(t, ">", reader.SelectAttrValue(trackings[0], "offset", ""))
+	assert.Equal(t, "http://t.com/?a>b", reader.Text(trackings[0]))
+	assert.Equal(t, "y>", reader.SelectAttrValue(trackings[1], "event", ""))
+
+	updater := NewXMLUpdater(reader, WriteSettings{})
+	updater.UpdateAttributeValue(reader.SelectAttr(trackings[0], "offset"), "00:00:05")
+	updater.UpdateText(trackings[0], "http://new.com", true, NoEscaping)
+	updater.AddAttribute(trackings[1], "", "offset", "10%")
+	assert.Equal(t, `<TrackingEvents><Tracking event="x" offset="00:00:05"><![CDATA[http://new.com]]></Tracking><Tracking offset="10%" event='y>'/></TrackingEvents>`, updater.String())
+}
+
+func TestXMLUpdaterComments(t *testing.T) {
+	in := `<!-- served by ad-01 --><VAST><Ad><!-- adid=123 --><?trace id=7?><Wrapper/></Ad></VAST>`
+	tests := []struct {
+		name   string
+		update func(*XMLReader, *XMLUpdater)
+		want   string
+	}{
+		{
+			name: "remove_comments",
+			update: func(xr *XMLReader, xu *XMLUpdater) {
+				for _, comment := range xr.Comments(nil) {
+					xu.RemoveElement(comment)
+				}
+			},
+			want: `<VAST><Ad><?trace id=7?><Wrapper/></Ad></VAST>`,
+		},
+		{
+			name: "replace_procinst",
+			update: func(xr *XMLReader, xu *XMLUpdater) {
+				xu.ReplaceElement(xr.ProcInsts(nil)[0], NewXMLText(`<Trace>7</Trace>`, false, NoEscaping))
+			},
+			want: `<!-- served by ad-01 --><VAST><Ad><!-- adid=123 --><Trace>7</Trace><Wrapper/></Ad></VAST>`,
+		},
+		{
+			name: "before_comment",
+			update: func(xr *XMLReader, xu *XMLUpdater) {
+				xu.BeforeElement(xr.Comments(nil)[1], NewXMLText(`<AdSystem/>`, false, NoEscaping))
+			},
+			want: `<!-- served by ad-01 --><VAST><Ad><AdSystem/><!-- adid=123 --><?trace id=7?><Wrapper/></Ad></VAST>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := NewXMLReaderWithOptions(ParseOptions{CharData: true})
+			if !assert.NoError(t, reader.Parse([]byte(in))) {
+				return
+			}
+			updater := NewXMLUpdater(reader, WriteSettings{})
+			tt.update(reader, updater)
+			assert.Equal(t, tt.want, updater.String())
+		})
+	}
+}
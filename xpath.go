@@ -5,26 +5,129 @@ import (
 	"strings"
 )
 
+// xpathPredicate is attribute predicate of path step eg: [@event='start'] or [@event]
+type xpathPredicate struct {
+	attr, value string
+	exists      bool //only presence of attribute is checked
+}
+
+/*
+xpath is a rule node of path trie, step is an element name, * wildcard, // descendant
+axis followed by next step and any of them can be followed by attribute predicates
+eg: {"VAST", "Ad", "*", "//", "Tracking[@event='start']"}
+*/
 type xpath struct {
 	data   string
-	childs map[string]*xpath
+	childs map[string]*xpath //child steps matching only element name
+	steps  []*xpath          //child steps with wildcard, predicate or descendant axis
+
+	name       string //element name, * for any element
+	preds      []xpathPredicate
+	descendant bool //step matches at any depth below its parent
+	deep       bool //any of steps has descendant axis
 }
 
 type XPath = xpath
 
+// xpathState is rule node active for open element, descendantOnly node is kept only for its descendant steps
+type xpathState struct {
+	node           *xpath
+	descendantOnly bool
+}
+
+// xpathFrame holds rule nodes active for open element at depth
+type xpathFrame struct {
+	depth   int
+	si, ei  int  //states of XMLTokenizer
+	matched bool //element matched a step and is reported
+}
+
 func (n *xpath) add(path []string) {
 	tempNode := n
+	descendant := false
 	for _, key := range path {
-		childNode, ok := tempNode.childs[key]
-		if !ok {
-			childNode = &xpath{
-				data:   key,
-				childs: make(map[string]*xpath),
+		if key == "//" {
+			descendant = true
+			continue
+		}
+		if strings.HasPrefix(key, "//") {
+			key, descendant = key[2:], true
+		}
+
+		childNode := tempNode.getStep(key, descendant)
+		if childNode == nil {
+			childNode = newXPathStep(key, descendant)
+			if childNode.isSimple() {
+				tempNode.childs[key] = childNode
+			} else {
+				tempNode.steps = append(tempNode.steps, childNode)
+				tempNode.deep = tempNode.deep || descendant
 			}
-			tempNode.childs[key] = childNode
 		}
 		tempNode = childNode
+		descendant = false
+	}
+}
+
+func newXPathStep(key string, descendant bool) *xpath {
+	step := &xpath{
+		data:       key,
+		childs:     make(map[string]*xpath),
+		name:       key,
+		descendant: descendant,
+	}
+	if descendant {
+		step.data = "//" + key
+	}
+	if i := strings.IndexByte(key, '['); i != -1 {
+		step.name = key[:i]
+		step.preds = parsePredicates(key[i:])
+	}
+	return step
+}
+
+// parsePredicates parses [@attr='value'][@attr="value"][@attr] list, invalid predicates are ignored
+func parsePredicates(s string) (preds []xpathPredicate) {
+	for len(s) > 0 && s[0] == '[' {
+		end := strings.IndexByte(s, ']')
+		if end == -1 {
+			break
+		}
+		pred := strings.TrimSpace(s[1:end])
+		s = s[end+1:]
+		if !strings.HasPrefix(pred, "@") {
+			continue
+		}
+		attr, value, found := strings.Cut(pred[1:], "=")
+		attr, value = strings.TrimSpace(attr), strings.TrimSpace(value)
+		if !found {
+			preds = append(preds, xpathPredicate{attr: attr, exists: true})
+			continue
+		}
+		if len(value) >= 2 && (value[0] == '\'' || value[0] == '"') && value[len(value)-1] == value[0] {
+			preds = append(preds, xpathPredicate{attr: attr, value: value[1 : len(value)-1]})
+		}
 	}
+	return preds
+}
+
+func (n *xpath) isSimple() bool {
+	return n.name != "*" && len(n.preds) == 0 && !n.descendant
+}
+
+// getStep returns child step with the same definition
+func (n *xpath) getStep(key string, descendant bool) *xpath {
+	if descendant {
+		key = "//" + key
+	} else if p := n.childs[key]; p != nil {
+		return p
+	}
+	for _, step := range n.steps {
+		if step.data == key {
+			return step
+		}
+	}
+	return nil
 }
 
 func (n *xpath) get(key string) (val *xpath) {
@@ -37,7 +140,40 @@ func (n *xpath) get(key string) (val *xpath) {
 			}
 		}
 	*/
-	return n.childs[key]
+	if p := n.childs[key]; p != nil {
+		return p
+	}
+	for _, step := range n.steps {
+		if step.data == key {
+			return step
+		}
+	}
+	return nil
+}
+
+// match checks element name and attribute predicates of step against start tag
+func (n *xpath) match(in []byte, token *XMLToken) bool {
+	if n.name != "*" && n.name != string(token.Name(in)) {
+		return false
+	}
+	if len(n.preds) == 0 {
+		return true
+	}
+	//NOTE: token end may not be known yet, trailing '/' of inline token is ignored by parseAttributes
+	attrs := parseAttributes(in, token.name.ei, token.start.ei-1)
+	for _, pred := range n.preds {
+		found := false
+		for _, attr := range attrs {
+			if string(attr.Key(in)) == pred.attr && (pred.exists || string(attr.Value(in)) == pred.value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
 }
 
 func (n *xpath) print(buf *bytes.Buffer, indent int) {
@@ -49,6 +185,9 @@ func (n *xpath) print(buf *bytes.Buffer, indent int) {
 	for _, child := range n.childs {
 		child.print(buf, indent)
 	}
+	for _, child := range n.steps {
+		child.print(buf, indent)
+	}
 }
 
 func (n *xpath) String() string {
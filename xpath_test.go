@@ -1,7 +1,10 @@
 package fastxml
 
 import (
+	"strings"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestXPath_AddAndGet(t *testing.T) {
@@ -83,3 +86,113 @@ func TestGetXPath(t *testing.T) {
 		}
 	}
 }
+
+func TestXPathSteps(t *testing.T) {
+	in := []byte(`<VAST><Ad id="1"><InLine><Creatives><Creative><Linear><TrackingEvents><Tracking event="start">s1</Tracking><Tracking event="complete">c1</Tracking><Tracking event="start"/></TrackingEvents></Linear></Creative></Creatives></InLine></Ad><Ad id="2"><Wrapper><Tracking event="start">s2</Tracking><Error>e2</Error></Wrapper></Ad></VAST>`)
+
+	tests := []struct {
+		name  string
+		paths [][]string
+		want  string //name:parent of reported elements in post order
+	}{
+		{
+			name:  "exact",
+			paths: [][]string{{"VAST", "Ad", "Wrapper", "Error"}},
+			want:  "Ad:VAST Error:Wrapper Wrapper:Ad Ad:VAST VAST:",
+		},
+		{
+			name:  "wildcard",
+			paths: [][]string{{"VAST", "Ad", "*", "Error"}},
+			want:  "InLine:Ad Ad:VAST Error:Wrapper Wrapper:Ad Ad:VAST VAST:",
+		},
+		{
+			name:  "predicate",
+			paths: [][]string{{"VAST", "Ad[@id='2']", "Wrapper", "Tracking[@event=\"start\"]"}},
+			want:  "Tracking:Wrapper Wrapper:Ad Ad:VAST VAST:",
+		},
+		{
+			name:  "descendant",
+			paths: [][]string{{"VAST", "//", "Tracking[@event='start']"}},
+			want:  "Tracking:VAST Tracking:VAST Tracking:VAST VAST:",
+		},
+		{
+			name:  "descendant_step_prefix",
+			paths: [][]string{{"VAST", "Ad[@id='1']", "//TrackingEvents", "Tracking[@event='complete']"}},
+			want:  "Tracking:TrackingEvents TrackingEvents:Ad Ad:VAST VAST:",
+		},
+		{
+			name:  "descendant_with_child_sibling",
+			paths: [][]string{{"VAST", "Wrapper"}, {"VAST", "//", "Error"}},
+			want:  "Error:VAST VAST:",
+		},
+		{
+			name:  "attribute_exists",
+			paths: [][]string{{"VAST", "Ad", "Wrapper", "*[@event]"}},
+			want:  "Ad:VAST Tracking:Wrapper Wrapper:Ad Ad:VAST VAST:",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			err := NewXMLTokenizer().ParseWithXPath(in, GetXPath(tt.paths), func(name string, parent *Element, child Element) {
+				parentName := ""
+				if parent != nil {
+					parentName = string(parent.data.Name(in))
+				}
+				got = append(got, name+":"+parentName)
+			})
+			if assert.NoError(t, err) {
+				assert.Equal(t, tt.want, strings.Join(got, " "))
+			}
+		})
+	}
+}
+
+func TestXPathStepsReader(t *testing.T) {
+	in := []byte(`<VAST><Ad><InLine><Creatives><Creative><Linear><TrackingEvents><Tracking event="start">s</Tracking><Tracking event="complete">c</Tracking></TrackingEvents></Linear></Creative></Creatives></InLine></Ad></VAST>`)
+	reader := NewXMLReader()
+	err := reader.ParseWithXPath(in, GetXPath([][]string{{"VAST", "//", "Tracking[@event='start']"}}))
+	if !assert.NoError(t, err) {
+		return
+	}
+	trackings := reader.SelectElements(nil, "VAST", "Tracking")
+	if assert.Len(t, trackings, 1) {
+		assert.Equal(t, "s", reader.Text(trackings[0]))
+	}
+}
+
+func TestXPathDescendantOnlyDeep(t *testing.T) {
+	//plain child rule of parent with descendant step must not match deeper
+	in := []byte(`<VAST><X><Ad><Tracking>t</Tracking></Ad></X><Ad>a</Ad></VAST>`)
+	rule := GetXPath([][]string{{"VAST", "Ad"}, {"VAST", "//", "Tracking"}})
+	var got []string
+	err := NewXMLTokenizer().ParseWithXPath(in, rule, func(name string, parent *Element, child Element) {
+		got = append(got, name+":"+string(child.data.XMLTag(in)))
+	})
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"Tracking:<Tracking>t</Tracking>", "Ad:<Ad>a</Ad>", "VAST:" + string(in)}, got)
+	}
+}
+
+func TestGetXPathSteps(t *testing.T) {
+	rule := GetXPath([][]string{
+		{"a", "*", "c[@k='v'][@x]"},
+		{"a", "*", "//", "d"},
+	})
+	a := rule.get("a")
+	if !assert.NotNil(t, a) {
+		return
+	}
+	star := a.get("*")
+	if !assert.NotNil(t, star) {
+		return
+	}
+	assert.True(t, star.deep)
+	if c := star.get("c[@k='v'][@x]"); assert.NotNil(t, c) {
+		assert.Equal(t, "c", c.name)
+		assert.Equal(t, []xpathPredicate{{attr: "k", value: "v"}, {attr: "x", exists: true}}, c.preds)
+	}
+	if d := star.get("//d"); assert.NotNil(t, d) {
+		assert.True(t, d.descendant)
+	}
+}